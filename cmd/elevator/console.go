@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Console commands for inputs the simulator has no buttons for
+const (
+	cmdIndependent = "independent" // independent on|off
+	cmdBoard       = "board"       // board <passengers>
+	cmdAlight      = "alight"      // alight <passengers>
+	cmdStatus      = "status"
+	cmdDest        = "dest" // dest <from> <to>, in destination dispatch mode
+)
+
+type command struct {
+	name     string
+	on       bool
+	count    int
+	from, to int
+}
+
+// readConsole parses one command per line from r
+func readConsole(r io.Reader, cmds chan<- command) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		c, err := parseCommand(scanner.Text())
+		if err != nil {
+			fmt.Printf("[CONSOLE] %v\n", err)
+			continue
+		}
+		cmds <- c
+	}
+}
+
+func parseCommand(line string) (command, error) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return command{}, fmt.Errorf("empty command")
+	}
+
+	c := command{name: fields[0]}
+	switch c.name {
+	case cmdIndependent:
+		if len(fields) != 2 || (fields[1] != "on" && fields[1] != "off") {
+			return c, fmt.Errorf("usage: %s on|off", cmdIndependent)
+		}
+		c.on = fields[1] == "on"
+
+	case cmdBoard, cmdAlight:
+		if len(fields) != 2 {
+			return c, fmt.Errorf("usage: %s <passengers>", c.name)
+		}
+		count, err := strconv.Atoi(fields[1])
+		if err != nil || count < 0 {
+			return c, fmt.Errorf("invalid passenger count %q", fields[1])
+		}
+		c.count = count
+
+	case cmdDest:
+		if len(fields) != 3 {
+			return c, fmt.Errorf("usage: %s <from> <to>", cmdDest)
+		}
+		from, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return c, fmt.Errorf("invalid floor %q", fields[1])
+		}
+		to, err := strconv.Atoi(fields[2])
+		if err != nil {
+			return c, fmt.Errorf("invalid floor %q", fields[2])
+		}
+		c.from, c.to = from, to
+
+	case cmdStatus:
+
+	default:
+		return c, fmt.Errorf("unknown command %q", c.name)
+	}
+
+	return c, nil
+}
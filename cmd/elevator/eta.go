@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Mosazghi/elevator-ttk4145/internal/orders"
+	statesync "github.com/Mosazghi/elevator-ttk4145/internal/sync"
+)
+
+// waitLog logs the first predicted wait of every hall call next to how long
+// it actually waited, to calibrate the travel-time model against
+type waitLog struct {
+	pending map[orders.HallCall]waitPrediction
+}
+
+type waitPrediction struct {
+	at        time.Time
+	car       int
+	predicted time.Duration
+}
+
+func newWaitLog() *waitLog {
+	return &waitLog{pending: make(map[orders.HallCall]waitPrediction)}
+}
+
+// update takes the latest ETAs. A call missing from them has been answered.
+func (l *waitLog) update(now time.Time, etas []orders.ETA) {
+	active := make(map[orders.HallCall]bool, len(etas))
+	for _, eta := range etas {
+		active[eta.Call] = true
+		if _, ok := l.pending[eta.Call]; ok || eta.Car == orders.NoCar {
+			continue
+		}
+		l.pending[eta.Call] = waitPrediction{at: now, car: eta.Car, predicted: eta.In}
+	}
+
+	for call, p := range l.pending {
+		if active[call] {
+			continue
+		}
+		fmt.Printf("[ETA] floor %d %s: predicted %.1fs (car %d), actual %.1fs\n",
+			call.Floor, dirName(call.Dir), p.predicted.Seconds(), p.car, now.Sub(p.at).Seconds())
+		delete(l.pending, call)
+	}
+}
+
+func dirName(dir statesync.HallCallDir) string {
+	if dir == statesync.HDUp {
+		return "up"
+	}
+	return "down"
+}
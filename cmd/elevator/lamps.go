@@ -0,0 +1,46 @@
+package main
+
+import (
+	eIO "github.com/Mosazghi/elevator-ttk4145/internal/hw"
+	statesync "github.com/Mosazghi/elevator-ttk4145/internal/sync"
+)
+
+// lampPanel lights the hall lamps from the worldview. A lamp lights once
+// every alive elevator has acknowledged the call, so that it promises the
+// call will be served, and goes dark once it is. Only lamps that change are
+// written to the driver.
+type lampPanel struct {
+	io  eIO.ElevatorDriver
+	lit [][2]bool
+}
+
+func newLampPanel(io eIO.ElevatorDriver, numFloors int) *lampPanel {
+	p := &lampPanel{
+		io:  io,
+		lit: make([][2]bool, numFloors),
+	}
+
+	// Start from a known state
+	for floor := range p.lit {
+		for dir := range p.lit[floor] {
+			io.SetButtonLamp(eIO.ButtonType(dir), floor, false)
+		}
+	}
+
+	return p
+}
+
+func (p *lampPanel) update(hallCalls [][2]statesync.HallCallPairState) {
+	for floor := range hallCalls {
+		if floor >= len(p.lit) {
+			break
+		}
+		for dir, call := range hallCalls[floor] {
+			on := call.State != statesync.HSNone
+			if on != p.lit[floor][dir] {
+				p.io.SetButtonLamp(eIO.ButtonType(dir), floor, on)
+				p.lit[floor][dir] = on
+			}
+		}
+	}
+}
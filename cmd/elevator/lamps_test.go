@@ -0,0 +1,85 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	eIO "github.com/Mosazghi/elevator-ttk4145/internal/hw"
+	statesync "github.com/Mosazghi/elevator-ttk4145/internal/sync"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeDriver records the lamps the panel sets
+type fakeDriver struct {
+	lamps [4][3]bool
+}
+
+func (d *fakeDriver) ReadInitialButtons() [4][3]bool           { return [4][3]bool{} }
+func (d *fakeDriver) SetMotorDirection(dir eIO.MotorDirection) {}
+func (d *fakeDriver) SetButtonLamp(button eIO.ButtonType, floor int, value bool) {
+	d.lamps[floor][button] = value
+}
+func (d *fakeDriver) SetFloorIndicator(floor int)                     {}
+func (d *fakeDriver) SetDoorOpenLamp(value bool)                      {}
+func (d *fakeDriver) SetStopLamp(value bool)                          {}
+func (d *fakeDriver) GetButton(button eIO.ButtonType, floor int) bool { return false }
+func (d *fakeDriver) GetFloor() int                                   { return -1 }
+func (d *fakeDriver) GetStop() bool                                   { return false }
+func (d *fakeDriver) GetTotalFloors() int                             { return 4 }
+func (d *fakeDriver) GetObstruction() bool                            { return false }
+func (d *fakeDriver) PollButtons(receiver chan<- eIO.ButtonEvent)     {}
+func (d *fakeDriver) PollFloorSensor(receiver chan<- int)             {}
+func (d *fakeDriver) PollStopButton(receiver chan<- bool)             {}
+func (d *fakeDriver) PollObstructionSwitch(receiver chan<- bool)      {}
+
+// A hall lamp follows the call through the worldview: dark until every alive
+// node has seen it, lit while it waits, also for a new car after its first
+// one is lost, and dark once it is served
+func TestLampPanel_FollowsWorldview(t *testing.T) {
+	drv := &fakeDriver{}
+	p := newLampPanel(drv, 4)
+	wv1 := statesync.NewWorldView(1, 4)
+	wv2 := statesync.NewWorldView(2, 4)
+	wv3 := statesync.NewWorldView(3, 4)
+
+	gossip := func() {
+		t.Helper()
+		for range 2 {
+			for _, a := range []*statesync.Worldview{wv1, wv2, wv3} {
+				for _, b := range []*statesync.Worldview{wv1, wv2, wv3} {
+					if a != b {
+						require.NoError(t, a.Merge(b))
+					}
+				}
+			}
+		}
+	}
+	lit := func() bool {
+		view := wv1.Snapshot()
+		p.update(view.ConfirmedHallCalls())
+		return drv.lamps[2][eIO.HallDown]
+	}
+
+	gossip()
+	require.NoError(t, wv1.SetHallCall(2, statesync.HDDown, statesync.HSAvailable))
+	assert.False(t, lit(), "lamp should stay dark until the peers have seen the call")
+
+	gossip()
+	assert.True(t, lit(), "lamp should light once the call is confirmed")
+
+	require.NoError(t, wv3.SetHallCall(2, statesync.HDDown, statesync.HSProcessing))
+	gossip()
+	assert.True(t, lit())
+
+	// Node 3 is lost while node 2 is still heard from
+	now := time.Now()
+	wv1.Members().Heartbeat(2, now.Add(2*statesync.NodeTimeoutDelay))
+	wv1.Members().Expire(now.Add(2 * statesync.NodeTimeoutDelay))
+	require.Equal(t, []int{3}, wv1.GetLostElevatorIDs())
+	assert.True(t, lit(), "lamp should stay lit while the call waits for a new car")
+
+	require.NoError(t, wv1.SetHallCall(2, statesync.HDDown, statesync.HSProcessing))
+	require.NoError(t, wv1.SetHallCall(2, statesync.HDDown, statesync.HSNone))
+	assert.False(t, lit(), "lamp should go dark once the call is served")
+}
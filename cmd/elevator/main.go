@@ -1,94 +1,96 @@
 package main
 
 import (
-	//"flag"
 	"flag"
 	"fmt"
-	"time"
+	"os"
 
-	//"github.com/Mosazghi/elevator-ttk4145/internal/elevator"
-	//eIO "github.com/Mosazghi/elevator-ttk4145/internal/hw"
-
-	network "github.com/Mosazghi/elevator-ttk4145/internal/net"
+	"github.com/Mosazghi/elevator-ttk4145/internal/config"
+	"github.com/Mosazghi/elevator-ttk4145/internal/elevator"
+	eIO "github.com/Mosazghi/elevator-ttk4145/internal/hw"
+	"github.com/Mosazghi/elevator-ttk4145/internal/orders"
+	statesync "github.com/Mosazghi/elevator-ttk4145/internal/sync"
 )
 
-// var numFloors = 4
 func main() {
 	portNum := flag.String("port", "15657", "specify port number")
-	id := flag.Int("id", 1, "specify elevator ID")
+	id := flag.Int("id", 1, "specify elevator ID, from 1")
+	configPath := flag.String("config", "config.json", "specify config file")
+	travelModelPath := flag.String("travel-model", "", "specify file to keep the learned travel times in")
 
 	flag.Parse()
 	fmt.Println("ID: ", *id)
 	fmt.Println("portNum: ", *portNum)
 
-	// 	drvButtons := make(chan eIO.ButtonEvent)
-	// 	drvFloors := make(chan int)
-	// 	drvObstr := make(chan bool)
-	// 	drvStop := make(chan bool)
+	// ID 0 marks a call without an owner in the worldview
+	if *id <= 0 {
+		fmt.Printf("Invalid elevator ID: %d\n", *id)
+		os.Exit(1)
+	}
 
-	// 	elevIoDriver := eIO.NewElevIoDriver("localhost:"+*portNum, 4)
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		fmt.Printf("Failed to load config: %v\n", err)
+		os.Exit(1)
+	}
 
-	// 	go elevIoDriver.PollButtons(drvButtons)
-	// 	go elevIoDriver.PollFloorSensor(drvFloors)
-	// 	go elevIoDriver.PollObstructionSwitch(drvObstr)
-	// 	go elevIoDriver.PollStopButton(drvStop)
+	// The local state machine has a fixed order matrix
+	if cfg.NumFloors != len(elevator.ElevState{}.Orders) {
+		fmt.Printf("Unsupported number of floors: %d\n", cfg.NumFloors)
+		os.Exit(1)
+	}
 
-	// 	initFloor := elevIoDriver.GetFloor()
+	timing := orders.TimingFromConfig(cfg)
+	assigner, err := orders.NewAssigner(cfg.Assigner, timing)
+	if err != nil {
+		fmt.Printf("Failed to create assigner: %v (available: %v)\n", err, orders.Strategies)
+		os.Exit(1)
+	}
 
-	// 	elev := elevator.NewElevState(initFloor, elevIoDriver.ReadInitialButtons(), elevIoDriver)
+	if a, ok := assigner.(*orders.ExecAssigner); ok && cfg.HRAExecutable != "" {
+		a.Path = cfg.HRAExecutable
+	}
 
-	// 	if initFloor == -1 {
-	// 		elev.OnInitBetweenFloors()
-	// 	}
+	assigner = orders.WithHysteresis(assigner, timing, cfg.AssignmentMargin())
 
-	// Start network
-	txChan, rxChan, errChan, err := network.UDPRunNetwork()
-	if err != nil {
-		fmt.Printf("Failed to start network: %v\n", err)
-		return
+	drvButtons := make(chan eIO.ButtonEvent)
+	drvFloors := make(chan int)
+	drvObstr := make(chan bool)
+	drvStop := make(chan bool)
+
+	var elevIoDriver eIO.ElevatorDriver = eIO.NewElevIoDriver("localhost:"+*portNum, cfg.NumFloors)
+	if cfg.LoadCapacity > 0 {
+		elevIoDriver = eIO.NewLoadSimDriver(elevIoDriver, cfg.LoadCapacity)
 	}
 
-	ticker := time.NewTicker(2 * time.Second)
-	defer ticker.Stop()
+	go elevIoDriver.PollButtons(drvButtons)
+	go elevIoDriver.PollFloorSensor(drvFloors)
+	go elevIoDriver.PollObstructionSwitch(drvObstr)
+	go elevIoDriver.PollStopButton(drvStop)
+
+	wv := statesync.NewWorldView(*id, cfg.NumFloors)
+	if err := wv.StartSyncing(cfg.SyncPort, *id); err != nil {
+		fmt.Printf("Failed to start syncing: %v\n", err)
+		os.Exit(1)
+	}
 
-	// Handle all channels
-	for {
-		select {
-		case msg := <-rxChan:
-			fmt.Printf("Received: %s from %s\n", string(msg.Data), msg.Address.String())
+	travelModel, err := orders.LoadTravelModel(*travelModelPath, cfg.NumFloors, timing)
+	if err != nil {
+		fmt.Printf("Failed to load travel model: %v\n", err)
+		os.Exit(1)
+	}
 
-		case err := <-errChan:
-			fmt.Printf("Network error: %v\n", err)
+	n := newNode(*id, cfg, elevIoDriver, wv, assigner, newTravelRecorder(travelModel, *travelModelPath))
+	if n.destPanel != nil {
+		go n.destPanel.PollButtons(drvButtons)
+	}
 
-		case <-ticker.C:
-			txChan <- network.UDPMessage{Data: []byte("Hello from A")}
-			fmt.Println("Sent broadcast message")
-		}
+	if elevIoDriver.GetFloor() == -1 {
+		n.elev.OnInitBetweenFloors()
 	}
 
-	// stateMachine(drvButtons, drvFloors, drvObstr, drvStop, elev)
-}
+	consoleCmds := make(chan command)
+	go readConsole(os.Stdin, consoleCmds)
 
-// func stateMachine(drvButtons chan eIO.ButtonEvent, drvFloors chan int, drvObst chan bool, drvStop chan bool, elev *elevator.ElevState) {
-
-// 	prevBehavior := elevator.BIdle
-
-// 	for {
-
-// 		if prevBehavior != elev.Behavior {
-// 			fmt.Printf("State Trans: %v -> %v\n", prevBehavior, elev.Behavior)
-// 			prevBehavior = elev.Behavior
-// 		}
-
-// 		select {
-// 		case a := <-drvButtons:
-// 			elev.OnOrderRequest(a)
-// 		case a := <-drvFloors:
-// 			elev.OnNewFloorArrival(a)
-// 		case a := <-drvObst:
-// 			elev.OnObstructionSignal(a)
-// 		case a := <-drvStop:
-// 			elev.OnStopSignal(a)
-// 		}
-// 	}
-// }
+	n.run(drvButtons, drvFloors, drvObstr, drvStop, consoleCmds)
+}
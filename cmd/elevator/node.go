@@ -0,0 +1,418 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Mosazghi/elevator-ttk4145/internal/config"
+	"github.com/Mosazghi/elevator-ttk4145/internal/elevator"
+	eIO "github.com/Mosazghi/elevator-ttk4145/internal/hw"
+	"github.com/Mosazghi/elevator-ttk4145/internal/orders"
+	statesync "github.com/Mosazghi/elevator-ttk4145/internal/sync"
+)
+
+// node ties the local state machine to the shared worldview. All of its
+// methods run on the goroutine that called run.
+type node struct {
+	id       int
+	cfg      *config.Config
+	io       eIO.ElevatorDriver
+	elev     *elevator.ElevState
+	wv       *statesync.Worldview
+	dispatch *orders.Dispatcher
+	timing   orders.Timing
+	lamps    *lampPanel
+
+	// etas are the estimated arrivals for the active hall calls, as of the
+	// last assignment
+	etas  []orders.ETA
+	waits *waitLog
+
+	travel *travelRecorder
+
+	peers <-chan statesync.PeerEvent
+	// standalone is set while no peer is alive, see updateNetworkMode
+	standalone bool
+
+	// changes delivers the worldview whenever it changes, and view is the
+	// latest one delivered
+	changes <-chan statesync.WorldviewSnapshot
+	view    statesync.WorldviewSnapshot
+
+	// Only set in destination dispatch mode
+	destAssigner *orders.DestinationAssigner
+	destPanel    *eIO.DestinationPanel
+	// boarded are the destinations of passengers let in while the state
+	// machine was serving their pickup, handed to it once it is done
+	boarded []eIO.ButtonEvent
+}
+
+func newNode(id int, cfg *config.Config, io eIO.ElevatorDriver, wv *statesync.Worldview, assigner orders.Assigner, travel *travelRecorder) *node {
+	timing := orders.TimingFromConfig(cfg)
+	n := &node{
+		id:       id,
+		cfg:      cfg,
+		io:       io,
+		wv:       wv,
+		dispatch: &orders.Dispatcher{Assigner: assigner, Timing: timing},
+		timing:   timing,
+		lamps:    newLampPanel(io, cfg.NumFloors),
+		waits:    newWaitLog(),
+		travel:   travel,
+		peers:    wv.Members().Subscribe(),
+		changes:  wv.Subscribe(),
+	}
+
+	n.elev = elevator.NewElevState(io.GetFloor(), [4][3]bool{}, io)
+	n.elev.DoorOpenDuration = cfg.DoorOpenDuration()
+	n.elev.FullLoadThreshold = cfg.FullLoadThreshold
+	n.elev.Served = n.onServed
+
+	if cfg.DispatchMode == config.DispatchDestination {
+		n.destAssigner = &orders.DestinationAssigner{Timing: n.timing}
+		n.destPanel = eIO.NewDestinationPanel(cfg.NumFloors)
+	}
+
+	return n
+}
+
+func (n *node) run(drvButtons <-chan eIO.ButtonEvent, drvFloors <-chan int, drvObstr, drvStop <-chan bool, cmds <-chan command) {
+	statusTicker := time.NewTicker(n.cfg.StatusPeriod())
+	defer statusTicker.Stop()
+
+	prevBehavior := n.elev.Behavior
+
+	for {
+		select {
+		case a := <-drvButtons:
+			n.onButton(a)
+		case a := <-drvFloors:
+			n.travel.onFloor(time.Now(), a)
+			n.elev.OnNewFloorArrival(a)
+		case a := <-drvObstr:
+			n.elev.OnObstructionSignal(a)
+		case a := <-drvStop:
+			n.elev.OnStopSignal(a)
+		case <-n.elev.DoorTimeout():
+			n.elev.OnDoorTimeout()
+		case c := <-cmds:
+			n.onCommand(c)
+		case err := <-n.wv.Errors():
+			fmt.Printf("[SYNC] %v\n", err)
+		case e := <-n.peers:
+			n.onPeerEvent(e)
+		case calls := <-n.wv.RestoredCabCalls():
+			n.restoreCabCalls(calls)
+		case view := <-n.changes:
+			n.onWorldviewChange(view)
+		case <-statusTicker.C:
+			n.printStatus()
+		}
+		n.takeBoarded()
+
+		if prevBehavior != n.elev.Behavior {
+			fmt.Printf("State Trans: %v -> %v\n", prevBehavior, n.elev.Behavior)
+			prevBehavior = n.elev.Behavior
+		}
+		n.travel.update(time.Now(), n.elev)
+
+		n.update()
+	}
+}
+
+// onWorldviewChange lights the hall lamps as of the changed worldview. The
+// assignment that follows every event takes the changed calls.
+func (n *node) onWorldviewChange(view statesync.WorldviewSnapshot) {
+	n.view = view
+	n.lamps.update(view.ConfirmedHallCalls())
+}
+
+// onPeerEvent logs a peer joining or being lost. The worldview has already
+// released a lost peer's hall calls, and the assignment that follows every
+// event hands them out again.
+func (n *node) onPeerEvent(e statesync.PeerEvent) {
+	switch e.Kind {
+	case statesync.PeerJoined:
+		fmt.Printf("[PEER] %v\n", e)
+	case statesync.PeerLost:
+		fmt.Printf("[PEER] %v, last seen %v ago, releasing its hall calls\n", e, e.At.Sub(e.LastSeen).Round(time.Millisecond))
+	}
+}
+
+// restoreCabCalls takes back the cab calls peers kept for us from before we
+// restarted, lighting their lamps
+func (n *node) restoreCabCalls(calls []bool) {
+	var floors []int
+	for floor, on := range calls {
+		if on && !n.elev.Orders[floor][eIO.Cab] {
+			n.elev.OnOrderRequest(eIO.ButtonEvent{Floor: floor, Button: eIO.Cab})
+			floors = append(floors, floor)
+		}
+	}
+	fmt.Printf("[SYNC] Restored cab calls to floors %v from peers\n", floors)
+}
+
+// updateNetworkMode logs switching between standalone and cluster mode. In
+// standalone mode the node is the only alive elevator, so it confirms and
+// takes every hall call it knows of by itself, those pressed at its own panel
+// and those released by the peers it lost. Once a peer is heard from again,
+// the merge joins the calls both sides took in the meantime.
+func (n *node) updateNetworkMode() {
+	standalone := n.wv.Isolated()
+	if standalone == n.standalone {
+		return
+	}
+	n.standalone = standalone
+
+	if standalone {
+		fmt.Println("[MODE] No peers alive, running standalone")
+	} else {
+		fmt.Printf("[MODE] Peers %v alive, back in the cluster\n", n.wv.Members().Alive())
+	}
+}
+
+// update publishes the local state and takes new hall calls
+func (n *node) update() {
+	n.updateNetworkMode()
+
+	local := statesync.NewRemoteElevatorStateFromLocal(n.id, n.cfg.NumFloors, n.elev)
+	local.SegmentTimes = n.travel.model.Segments()
+	local.DoorTime = n.travel.model.DoorCycle()
+	if err := n.wv.SetLocalElevator(local); err != nil {
+		fmt.Printf("Failed to publish local state: %v\n", err)
+	}
+
+	n.assignHallCalls()
+	n.waits.update(time.Now(), n.etas)
+}
+
+// assignHallCalls runs the assigner on the worldview. Every node computes the
+// same assignment, so each one takes the calls given to it and drops the
+// ones given to someone else. New calls wait until every alive node has
+// acknowledged them.
+func (n *node) assignHallCalls() {
+	plan := n.dispatch.Plan(n.wv)
+	hallCalls, states := plan.HallCalls, plan.States
+	mine := plan.Assignment[n.id]
+	n.etas = plan.ETAs
+
+	// The hall orders the car should hold, for hall calls and for picking up
+	// destination calls
+	wanted := make([][2]bool, len(hallCalls))
+
+	for floor := range hallCalls {
+		for dir, call := range hallCalls[floor] {
+			if mine == nil || !mine[floor][dir] {
+				continue
+			}
+
+			if call.State == statesync.HSAvailable || call.By != n.id {
+				if err := n.wv.SetHallCall(floor, statesync.HallCallDir(dir), statesync.HSProcessing); err != nil {
+					fmt.Printf("Failed to take hall call: %v\n", err)
+					continue
+				}
+			}
+			wanted[floor][dir] = true
+		}
+	}
+
+	for _, pickup := range n.assignDestCalls(hallCalls, states) {
+		wanted[pickup.Floor][pickup.Dir] = true
+	}
+
+	for floor := range wanted {
+		for dir := range wanted[floor] {
+			order := eIO.ButtonEvent{Floor: floor, Button: eIO.ButtonType(dir)}
+
+			switch {
+			case wanted[floor][dir] && !n.elev.Orders[floor][dir]:
+				n.elev.OnOrderRequest(order)
+			case !wanted[floor][dir] && n.elev.Orders[floor][dir]:
+				n.elev.OnOrderWithdrawn(order)
+			}
+		}
+	}
+}
+
+// assignDestCalls takes the destination calls assigned to this node and
+// returns the hall calls to answer to pick their passengers up
+func (n *node) assignDestCalls(hallCalls [][2]statesync.HallCallPairState, states []statesync.RemoteElevatorState) []orders.HallCall {
+	if n.destAssigner == nil {
+		return nil
+	}
+
+	destCalls := n.wv.GetConfirmedDestCalls()
+	mine := n.destAssigner.Assign(hallCalls, destCalls, states)[n.id]
+
+	var pickups []orders.HallCall
+	for from := range destCalls {
+		for to, call := range destCalls[from] {
+			if mine == nil || !mine[from][to] {
+				continue
+			}
+
+			if call.State == statesync.HSAvailable || call.By != n.id {
+				if err := n.wv.SetDestCall(from, to, statesync.HSProcessing); err != nil {
+					fmt.Printf("Failed to take destination call: %v\n", err)
+					continue
+				}
+			}
+			pickups = append(pickups, orders.DestCall{From: from, To: to}.Pickup())
+		}
+	}
+
+	return pickups
+}
+
+func (n *node) onButton(b eIO.ButtonEvent) {
+	if b.Button == eIO.Cab {
+		n.elev.OnOrderRequest(b)
+		return
+	}
+
+	if b.Button == eIO.HallDestination {
+		if n.wv.GetAllDestCalls()[b.Floor][b.Destination].State != statesync.HSNone {
+			return
+		}
+		if err := n.wv.SetDestCall(b.Floor, b.Destination, statesync.HSAvailable); err != nil {
+			fmt.Printf("Failed to register destination call: %v\n", err)
+		}
+		return
+	}
+
+	dir := statesync.HallCallDir(b.Button)
+	if n.wv.GetAllHallCalls()[b.Floor][dir].State != statesync.HSNone {
+		// Already known to the cluster
+		return
+	}
+
+	if err := n.wv.SetHallCall(b.Floor, dir, statesync.HSAvailable); err != nil {
+		fmt.Printf("Failed to register hall call: %v\n", err)
+	}
+}
+
+// onServed is called by the state machine whenever it has served an order
+func (n *node) onServed(order eIO.ButtonEvent) {
+	if order.Button == eIO.Cab {
+		n.wv.SetCabCall(order.Floor, false)
+		return
+	}
+
+	if err := n.wv.SetHallCall(order.Floor, statesync.HallCallDir(order.Button), statesync.HSNone); err != nil {
+		fmt.Printf("Failed to clear hall call: %v\n", err)
+	}
+
+	n.boardDestCalls(order.Floor, statesync.HallCallDir(order.Button))
+}
+
+// boardDestCalls lets in the passengers of our destination calls waiting at
+// the floor to go in the given direction, and queues where they are going
+func (n *node) boardDestCalls(floor int, dir statesync.HallCallDir) {
+	if n.destAssigner == nil {
+		return
+	}
+
+	for to, call := range n.wv.GetAllDestCalls()[floor] {
+		if call.State != statesync.HSProcessing || call.By != n.id {
+			continue
+		}
+		if (orders.DestCall{From: floor, To: to}).Dir() != dir {
+			continue
+		}
+
+		if err := n.wv.SetDestCall(floor, to, statesync.HSNone); err != nil {
+			fmt.Printf("Failed to clear destination call: %v\n", err)
+			continue
+		}
+		n.boarded = append(n.boarded, eIO.ButtonEvent{Floor: to, Button: eIO.Cab})
+	}
+}
+
+// takeBoarded hands the destinations of boarded passengers to the state
+// machine. They are queued by boardDestCalls, as it runs inside a state
+// machine event.
+func (n *node) takeBoarded() {
+	for len(n.boarded) > 0 {
+		order := n.boarded[0]
+		n.boarded = n.boarded[1:]
+		n.elev.OnOrderRequest(order)
+	}
+}
+
+func (n *node) onCommand(c command) {
+	switch c.name {
+	case cmdIndependent:
+		n.elev.OnIndependentServiceSwitch(c.on)
+		if c.on {
+			// Let the other cars pick up what we were serving
+			n.wv.ReleaseHallCalls(n.id)
+		}
+	case cmdBoard, cmdAlight:
+		sensor, ok := n.io.(*eIO.LoadSimDriver)
+		if !ok {
+			fmt.Println("No load sensor configured")
+			return
+		}
+		if c.name == cmdBoard {
+			sensor.Board(c.count)
+		} else {
+			sensor.Alight(c.count)
+		}
+		fmt.Printf("Load: %d/%d\n", sensor.GetLoad(), sensor.GetCapacity())
+	case cmdDest:
+		if n.destPanel == nil {
+			fmt.Println("Destination dispatch is not enabled")
+			return
+		}
+		if err := n.destPanel.Press(c.from, c.to); err != nil {
+			fmt.Printf("Failed to enter destination: %v\n", err)
+		}
+	case cmdStatus:
+		n.printStatus()
+	}
+}
+
+func (n *node) printStatus() {
+	fmt.Printf("[STATUS] id=%d floor=%d dir=%v behavior=%s door=%s mode=%s full=%v\n",
+		n.id, n.elev.CurrFloor, n.elev.Dir, n.elev.Behavior, n.elev.Door, n.elev.Mode, elevator.IsFull(n.elev))
+
+	var alive []int
+	for _, s := range n.view.ElevatorStates {
+		alive = append(alive, s.ID)
+	}
+	fmt.Printf("[STATUS]   network=%s alive=%v lost=%v\n", networkMode(n.standalone), alive, n.wv.GetLostElevatorIDs())
+	fmt.Printf("[STATUS]   learned segments=%v door=%v\n", n.travel.model.Segments(), n.travel.model.DoorCycle())
+
+	for floor, dirs := range n.view.HallCalls {
+		fmt.Printf("[STATUS]   floor %d: up=%s(%d) down=%s(%d) cab=%v\n", floor,
+			dirs[statesync.HDUp].State, dirs[statesync.HDUp].By,
+			dirs[statesync.HDDown].State, dirs[statesync.HDDown].By,
+			n.elev.Orders[floor][eIO.Cab])
+	}
+
+	for _, eta := range n.etas {
+		if eta.Car == orders.NoCar {
+			fmt.Printf("[STATUS]   eta floor %d %s: no car available\n", eta.Call.Floor, dirName(eta.Call.Dir))
+			continue
+		}
+		fmt.Printf("[STATUS]   eta floor %d %s: car %d in %.1fs\n", eta.Call.Floor, dirName(eta.Call.Dir), eta.Car, eta.In.Seconds())
+	}
+
+	if n.destAssigner == nil {
+		return
+	}
+	for from, calls := range n.view.DestCalls {
+		for to, call := range calls {
+			if call.State != statesync.HSNone {
+				fmt.Printf("[STATUS]   dest %d->%d: %s(%d)\n", from, to, call.State, call.By)
+			}
+		}
+	}
+}
+
+func networkMode(standalone bool) string {
+	if standalone {
+		return "standalone"
+	}
+	return "cluster"
+}
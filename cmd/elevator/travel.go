@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Mosazghi/elevator-ttk4145/internal/elevator"
+	"github.com/Mosazghi/elevator-ttk4145/internal/orders"
+)
+
+// travelRecorder times the car between floor arrivals and at its stops, and
+// feeds the samples to the travel model. The model is written to disk in the
+// background, so a slow disk does not hold up the event loop.
+type travelRecorder struct {
+	model *orders.TravelModel
+	path  string // where the model is saved, empty to keep it in memory
+	// pending holds the latest copy of the model not yet written
+	pending chan *orders.TravelModel
+
+	fromFloor int       // the floor the car last left or passed
+	fromAt    time.Time // zero while the car is not moving
+
+	doorAt       time.Time // zero while the door is not open
+	doorHeld     bool      // obstructed during this stop
+	prevBehavior elevator.Behavior
+}
+
+func newTravelRecorder(model *orders.TravelModel, path string) *travelRecorder {
+	r := &travelRecorder{model: model, path: path, prevBehavior: elevator.BIdle}
+	if path != "" {
+		r.pending = make(chan *orders.TravelModel, 1)
+		go r.write()
+	}
+	return r
+}
+
+// onFloor is called with every floor sensor event, before the state machine
+// handles it
+func (r *travelRecorder) onFloor(now time.Time, floor int) {
+	if r.fromAt.IsZero() || floor == r.fromFloor {
+		return
+	}
+
+	if r.model.ObserveSegment(r.fromFloor, floor, now.Sub(r.fromAt)) {
+		r.save()
+	}
+
+	r.fromFloor, r.fromAt = floor, now
+}
+
+// update follows the state machine after every event
+func (r *travelRecorder) update(now time.Time, e *elevator.ElevState) {
+	prev := r.prevBehavior
+	r.prevBehavior = e.Behavior
+
+	if e.Behavior == elevator.BDoorOpen && e.Obstructed {
+		r.doorHeld = true
+	}
+
+	if prev == e.Behavior {
+		return
+	}
+
+	switch {
+	case e.Behavior == elevator.BMoving:
+		r.fromFloor, r.fromAt = e.CurrFloor, now
+	case prev == elevator.BMoving:
+		r.fromAt = time.Time{}
+	}
+
+	switch {
+	case e.Behavior == elevator.BDoorOpen:
+		r.doorAt, r.doorHeld = now, e.Obstructed
+	case prev == elevator.BDoorOpen && !r.doorAt.IsZero():
+		// A held door says nothing about a normal stop
+		if !r.doorHeld && r.model.ObserveDoorCycle(now.Sub(r.doorAt)) {
+			r.save()
+		}
+		r.doorAt = time.Time{}
+	}
+}
+
+// save hands a copy of the model to the writer. A copy still waiting to be
+// written is out of date and is dropped.
+func (r *travelRecorder) save() {
+	if r.pending == nil {
+		return
+	}
+	select {
+	case <-r.pending:
+	default:
+	}
+	r.pending <- r.model.Clone()
+}
+
+// write saves the copies handed over by save, until the process exits
+func (r *travelRecorder) write() {
+	for m := range r.pending {
+		if err := m.Save(r.path); err != nil {
+			fmt.Printf("Failed to save travel model: %v\n", err)
+		}
+	}
+}
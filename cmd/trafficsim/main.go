@@ -0,0 +1,123 @@
+// Command trafficsim benchmarks the hall call assignment strategies on
+// simulated cars under synthetic passenger traffic, without any simulators
+// or network
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Mosazghi/elevator-ttk4145/internal/config"
+	"github.com/Mosazghi/elevator-ttk4145/internal/elevator"
+	"github.com/Mosazghi/elevator-ttk4145/internal/orders"
+)
+
+func main() {
+	configPath := flag.String("config", "config.json", "specify config file for timing and assignment margin")
+	numCars := flag.Int("cars", 3, "number of cars")
+	duration := flag.Duration("duration", time.Hour, "how long passengers keep arriving")
+	rate := flag.Float64("rate", 4, "passenger arrivals per minute")
+	profile := flag.String("profile", profileUniform, "traffic profile: uniform, up-peak or down-peak")
+	odPath := flag.String("od", "", "JSON origin/destination matrix, overrides -profile")
+	seed := flag.Int64("seed", 1, "random seed for the arrivals")
+	strategies := flag.String("strategies", "", "comma-separated strategies to compare (default: all built-in ones and destination dispatch)")
+	floorHeight := flag.Float64("floor-height", 3, "metres between floors, for car-km")
+
+	flag.Parse()
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		fmt.Printf("Failed to load config: %v\n", err)
+		os.Exit(1)
+	}
+
+	// The cars run the elevator package's request logic, which has a fixed
+	// order matrix
+	if cfg.NumFloors != len(elevator.ElevState{}.Orders) {
+		fmt.Printf("Unsupported number of floors: %d\n", cfg.NumFloors)
+		os.Exit(1)
+	}
+
+	if *numCars < 1 || *rate <= 0 {
+		fmt.Println("Need at least one car and a positive arrival rate")
+		os.Exit(1)
+	}
+
+	var od [][]float64
+	if *odPath != "" {
+		od, err = loadODMatrix(*odPath, cfg.NumFloors)
+	} else {
+		od, err = odMatrix(*profile, cfg.NumFloors)
+	}
+	if err != nil {
+		fmt.Printf("Failed to set up traffic: %v\n", err)
+		os.Exit(1)
+	}
+
+	names := defaultStrategies()
+	if *strategies != "" {
+		names = strings.Split(*strategies, ",")
+	}
+
+	arrivals := generateArrivals(rand.New(rand.NewSource(*seed)), od, *rate, *duration)
+	fmt.Printf("%d passengers over %v, %d cars, %d floors, profile %s\n",
+		len(arrivals), *duration, *numCars, cfg.NumFloors, trafficName(*profile, *odPath))
+
+	timing := orders.TimingFromConfig(cfg)
+
+	var results []result
+	for _, name := range names {
+		sim, err := newStrategySimulation(name, *numCars, cfg, timing)
+		if err != nil {
+			fmt.Printf("Skipping %s: %v\n", name, err)
+			continue
+		}
+
+		people := sim.run(arrivals)
+		results = append(results, summarize(name, people, sim.cars, *floorHeight))
+	}
+
+	printResults(os.Stdout, results)
+}
+
+// newStrategySimulation sets up the cars for a named assignment strategy, or
+// for destination dispatch
+func newStrategySimulation(name string, numCars int, cfg *config.Config, t orders.Timing) (*simulation, error) {
+	if name == config.DispatchDestination {
+		return newSimulation(numCars, cfg.NumFloors, t, nil, &orders.DestinationAssigner{Timing: t}), nil
+	}
+
+	assigner, err := orders.NewAssigner(name, t)
+	if err != nil {
+		return nil, err
+	}
+
+	if a, ok := assigner.(*orders.ExecAssigner); ok && cfg.HRAExecutable != "" {
+		a.Path = cfg.HRAExecutable
+	}
+
+	assigner = orders.WithHysteresis(assigner, t, cfg.AssignmentMargin())
+
+	return newSimulation(numCars, cfg.NumFloors, t, assigner, nil), nil
+}
+
+// defaultStrategies is every built-in strategy that runs without external
+// programs, and destination dispatch
+func defaultStrategies() []string {
+	names := slices.DeleteFunc(slices.Clone(orders.Strategies), func(s string) bool {
+		return s == orders.StrategyHRAExec
+	})
+	return append(names, config.DispatchDestination)
+}
+
+func trafficName(profile, odPath string) string {
+	if odPath != "" {
+		return odPath
+	}
+	return profile
+}
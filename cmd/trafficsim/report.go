@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"text/tabwriter"
+	"time"
+)
+
+// result sums up one strategy's run
+type result struct {
+	strategy   string
+	served     int
+	unserved   int
+	avgWait    time.Duration
+	p95Wait    time.Duration
+	avgJourney time.Duration // from arrival in the hall to arrival at the destination
+	p95Journey time.Duration
+	carKm      float64
+}
+
+func summarize(strategy string, people []*passenger, cars []*car, floorHeight float64) result {
+	r := result{strategy: strategy}
+
+	var waits, journeys []time.Duration
+	for _, p := range people {
+		if !p.served {
+			r.unserved++
+			continue
+		}
+		r.served++
+		waits = append(waits, p.boarded-p.arrival)
+		journeys = append(journeys, p.alighted-p.arrival)
+	}
+
+	r.avgWait, r.p95Wait = average(waits), percentile(waits, 0.95)
+	r.avgJourney, r.p95Journey = average(journeys), percentile(journeys, 0.95)
+
+	for _, c := range cars {
+		r.carKm += float64(c.floors) * floorHeight / 1000
+	}
+
+	return r
+}
+
+func average(ds []time.Duration) time.Duration {
+	if len(ds) == 0 {
+		return 0
+	}
+
+	var sum time.Duration
+	for _, d := range ds {
+		sum += d
+	}
+	return sum / time.Duration(len(ds))
+}
+
+// percentile returns the nearest-rank percentile, q in (0, 1]
+func percentile(ds []time.Duration, q float64) time.Duration {
+	if len(ds) == 0 {
+		return 0
+	}
+
+	sorted := slices.Clone(ds)
+	slices.Sort(sorted)
+
+	rank := int(math.Ceil(q*float64(len(sorted)))) - 1
+	return sorted[min(max(rank, 0), len(sorted)-1)]
+}
+
+func printResults(w io.Writer, results []result) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "strategy\tserved\tunserved\tavg wait\tp95 wait\tavg journey\tp95 journey\tcar-km\t")
+	for _, r := range results {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%.1fs\t%.1fs\t%.1fs\t%.1fs\t%.3f\t\n",
+			r.strategy, r.served, r.unserved,
+			r.avgWait.Seconds(), r.p95Wait.Seconds(),
+			r.avgJourney.Seconds(), r.p95Journey.Seconds(),
+			r.carKm)
+	}
+	tw.Flush()
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"slices"
+	"time"
+
+	"github.com/Mosazghi/elevator-ttk4145/internal/elevator"
+	elevio "github.com/Mosazghi/elevator-ttk4145/internal/hw"
+	"github.com/Mosazghi/elevator-ttk4145/internal/orders"
+	statesync "github.com/Mosazghi/elevator-ttk4145/internal/sync"
+)
+
+// simStep is the resolution of the simulated clock. Hall calls are assigned
+// once per step, like the node does on its assign ticker.
+const simStep = 100 * time.Millisecond
+
+// maxDrain is how long the simulation keeps running after the last arrival
+// to let the cars deliver the passengers still in the system
+const maxDrain = 30 * time.Minute
+
+// car is a simulated elevator. It runs the same request logic as the real
+// one, on a simulated clock instead of timers and a driver.
+type car struct {
+	id     int
+	e      *elevator.ElevState
+	next   time.Duration // when the current trip or door cycle ends
+	aboard []*passenger
+	floors int // floors travelled
+}
+
+// simulation runs cars answering passengers under one assignment strategy.
+// With dest set, passengers enter destination calls and assigner is unused.
+type simulation struct {
+	timing    orders.Timing
+	numFloors int
+	assigner  orders.Assigner
+	dest      *orders.DestinationAssigner
+	cars      []*car
+	hallCalls [][2]statesync.HallCallPairState
+	destCalls [][]statesync.HallCallPairState
+	waiting   [][]*passenger // by floor
+	now       time.Duration
+}
+
+func newSimulation(numCars, numFloors int, t orders.Timing, assigner orders.Assigner, dest *orders.DestinationAssigner) *simulation {
+	s := &simulation{
+		timing:    t,
+		numFloors: numFloors,
+		assigner:  assigner,
+		dest:      dest,
+		hallCalls: make([][2]statesync.HallCallPairState, numFloors),
+		destCalls: make([][]statesync.HallCallPairState, numFloors),
+		waiting:   make([][]*passenger, numFloors),
+	}
+
+	for from := range s.destCalls {
+		s.destCalls[from] = make([]statesync.HallCallPairState, numFloors)
+	}
+
+	for i := range numCars {
+		c := &car{
+			id: i + 1,
+			e: &elevator.ElevState{
+				Dir:      elevio.Stop,
+				Behavior: elevator.BIdle,
+				Door:     elevator.DSClosed,
+			},
+		}
+		// Spread the cars over the building to start with
+		c.e.CurrFloor = i * (numFloors - 1) / max(numCars-1, 1)
+		c.e.Served = func(order elevio.ButtonEvent) { s.onServed(c, order) }
+		s.cars = append(s.cars, c)
+	}
+
+	return s
+}
+
+// run lets the passengers arrive and keeps going until all of them have been
+// delivered or maxDrain has passed since the last arrival
+func (s *simulation) run(arrivals []passenger) []*passenger {
+	people := make([]*passenger, len(arrivals))
+	for i := range arrivals {
+		p := arrivals[i]
+		people[i] = &p
+	}
+
+	var end time.Duration
+	if len(people) > 0 {
+		end = people[len(people)-1].arrival + maxDrain
+	}
+
+	next := 0
+	for s.now = 0; s.now <= end; s.now += simStep {
+		for next < len(people) && people[next].arrival <= s.now {
+			s.arrive(people[next])
+			next++
+		}
+
+		s.assign()
+
+		for _, c := range s.cars {
+			s.stepCar(c)
+		}
+
+		if next == len(people) && s.delivered(people) {
+			break
+		}
+	}
+
+	return people
+}
+
+func (s *simulation) delivered(people []*passenger) bool {
+	for _, p := range people {
+		if !p.served {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *simulation) arrive(p *passenger) {
+	s.waiting[p.from] = append(s.waiting[p.from], p)
+
+	if s.dest != nil {
+		if s.destCalls[p.from][p.to].State == statesync.HSNone {
+			s.destCalls[p.from][p.to] = statesync.HallCallPairState{State: statesync.HSAvailable}
+		}
+		return
+	}
+
+	dir := orders.DestCall{From: p.from, To: p.to}.Dir()
+	if s.hallCalls[p.from][dir].State == statesync.HSNone {
+		s.hallCalls[p.from][dir] = statesync.HallCallPairState{State: statesync.HSAvailable}
+	}
+}
+
+// assign does what every node does on its assign ticker: run the assigner,
+// take the calls given to each car and drop the ones given elsewhere
+func (s *simulation) assign() {
+	states := make([]statesync.RemoteElevatorState, len(s.cars))
+	for i, c := range s.cars {
+		states[i] = *statesync.NewRemoteElevatorStateFromLocal(c.id, s.numFloors, c.e)
+	}
+
+	wanted := make(map[int][][2]bool, len(s.cars))
+	for _, c := range s.cars {
+		wanted[c.id] = make([][2]bool, s.numFloors)
+	}
+
+	if s.dest != nil {
+		result := s.dest.Assign(s.hallCalls, s.destCalls, states)
+		for id, calls := range result {
+			for from := range calls {
+				for to, assigned := range calls[from] {
+					if !assigned {
+						continue
+					}
+					s.destCalls[from][to] = statesync.HallCallPairState{State: statesync.HSProcessing, By: id}
+					pickup := orders.DestCall{From: from, To: to}.Pickup()
+					wanted[id][pickup.Floor][pickup.Dir] = true
+				}
+			}
+		}
+	} else {
+		result := s.assigner.Assign(s.hallCalls, states)
+		for id, calls := range result {
+			for floor := range calls {
+				for dir, assigned := range calls[floor] {
+					if !assigned {
+						continue
+					}
+					s.hallCalls[floor][dir] = statesync.HallCallPairState{State: statesync.HSProcessing, By: id}
+					wanted[id][floor][dir] = true
+				}
+			}
+		}
+	}
+
+	for _, c := range s.cars {
+		for floor := range s.numFloors {
+			for dir := range 2 {
+				c.e.Orders[floor][dir] = wanted[c.id][floor][dir]
+			}
+		}
+	}
+}
+
+// stepCar advances the car once its current trip or door cycle is over
+func (s *simulation) stepCar(c *car) {
+	if s.now < c.next {
+		return
+	}
+
+	e := c.e
+	switch e.Behavior {
+	case elevator.BIdle, elevator.BDoorOpen:
+		if e.Orders[e.CurrFloor] != [3]bool{} {
+			// Serve it on the spot, or reopen for a call that came in while
+			// the door was open
+			s.stop(c)
+			return
+		}
+
+		e.Door = elevator.DSClosed
+		e.Dir = elevio.Stop
+		e.Dir, e.Behavior = elevator.ChooseDirection(e)
+		if e.Behavior == elevator.BMoving {
+			c.next = s.now + s.timing.Travel
+		}
+
+	case elevator.BMoving:
+		e.CurrFloor += int(e.Dir)
+		c.floors++
+		if elevator.ShouldStop(e) {
+			e.Dir = elevio.Stop
+			s.stop(c)
+			return
+		}
+		c.next = s.now + s.timing.Travel
+	}
+}
+
+func (s *simulation) stop(c *car) {
+	elevator.ClearAtCurrentFloor(c.e)
+	c.e.Behavior = elevator.BDoorOpen
+	c.e.Door = elevator.DSOpen
+	c.next = s.now + s.timing.DoorOpen + elevator.DoorClosingDuration
+}
+
+// onServed lets passengers off and on, like the FSM's Served hook does for
+// the node
+func (s *simulation) onServed(c *car, order elevio.ButtonEvent) {
+	floor := order.Floor
+
+	if order.Button == elevio.Cab {
+		c.aboard = slices.DeleteFunc(c.aboard, func(p *passenger) bool {
+			if p.to != floor {
+				return false
+			}
+			p.alighted = s.now
+			p.served = true
+			return true
+		})
+		return
+	}
+
+	dir := statesync.HallCallDir(order.Button)
+	if s.dest == nil {
+		s.hallCalls[floor][dir] = statesync.HallCallPairState{}
+	}
+
+	s.waiting[floor] = slices.DeleteFunc(s.waiting[floor], func(p *passenger) bool {
+		call := orders.DestCall{From: p.from, To: p.to}
+		if call.Dir() != dir {
+			return false
+		}
+		if s.dest != nil && s.destCalls[p.from][p.to].By != c.id {
+			// Waiting for the car their destination call was given to
+			return false
+		}
+
+		p.boarded = s.now
+		c.aboard = append(c.aboard, p)
+		c.e.Orders[p.to][elevio.Cab] = true
+		return true
+	})
+
+	if s.dest == nil {
+		return
+	}
+	for to := range s.destCalls[floor] {
+		d := s.destCalls[floor][to]
+		if d.State == statesync.HSProcessing && d.By == c.id && (orders.DestCall{From: floor, To: to}).Dir() == dir {
+			s.destCalls[floor][to] = statesync.HallCallPairState{}
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/Mosazghi/elevator-ttk4145/internal/config"
+	"github.com/Mosazghi/elevator-ttk4145/internal/orders"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSimulation_DeliversEveryone(t *testing.T) {
+	cfg := config.Default()
+	timing := orders.TimingFromConfig(cfg)
+
+	for _, profile := range []string{profileUniform, profileUpPeak, profileDownPeak} {
+		od, err := odMatrix(profile, cfg.NumFloors)
+		require.NoError(t, err)
+		arrivals := generateArrivals(rand.New(rand.NewSource(1)), od, 6, 10*time.Minute)
+		require.NotEmpty(t, arrivals)
+
+		for _, name := range defaultStrategies() {
+			sim, err := newStrategySimulation(name, 3, cfg, timing)
+			require.NoError(t, err)
+
+			r := summarize(name, sim.run(arrivals), sim.cars, 3)
+
+			assert.Zero(t, r.unserved, "%s under %s left passengers behind", name, profile)
+			assert.LessOrEqual(t, r.avgWait, r.avgJourney, name)
+		}
+	}
+}
+
+func TestODMatrix_DownPeakMirrorsUpPeak(t *testing.T) {
+	up, err := odMatrix(profileUpPeak, 4)
+	require.NoError(t, err)
+	down, err := odMatrix(profileDownPeak, 4)
+	require.NoError(t, err)
+
+	for from := range up {
+		for to := range up[from] {
+			assert.Equal(t, up[from][to], down[to][from])
+		}
+	}
+	assert.InDelta(t, peakMainShare/3, up[0][2], 1e-9)
+}
+
+func TestPercentile(t *testing.T) {
+	var ds []time.Duration
+	for i := 1; i <= 20; i++ {
+		ds = append(ds, time.Duration(i)*time.Second)
+	}
+
+	assert.Equal(t, 19*time.Second, percentile(ds, 0.95))
+	assert.Equal(t, time.Duration(0), percentile(nil, 0.95))
+}
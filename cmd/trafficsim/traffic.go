@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"os"
+	"time"
+)
+
+// Traffic profiles for the origin/destination matrix
+const (
+	profileUniform  = "uniform"
+	profileUpPeak   = "up-peak"   // morning: most passengers leave the lobby
+	profileDownPeak = "down-peak" // evening: most passengers head for the lobby
+)
+
+// Shares of the passengers in a peak profile, the rest travel between upper
+// floors
+const (
+	peakMainShare    = 0.85 // from the lobby in up-peak, to it in down-peak
+	peakCounterShare = 0.05 // the opposite way
+)
+
+type passenger struct {
+	from, to int
+	arrival  time.Duration
+	boarded  time.Duration
+	alighted time.Duration
+	served   bool
+}
+
+// odMatrix returns the relative number of passengers travelling between each
+// pair of floors, by origin and then destination. Floor 0 is the lobby.
+func odMatrix(profile string, numFloors int) ([][]float64, error) {
+	od := make([][]float64, numFloors)
+	for from := range od {
+		od[from] = make([]float64, numFloors)
+	}
+
+	upper := float64(numFloors - 1)
+	interfloor := 1 - peakMainShare - peakCounterShare
+	for from := range od {
+		for to := range od[from] {
+			if from == to {
+				continue
+			}
+
+			switch profile {
+			case profileUniform:
+				od[from][to] = 1
+			case profileUpPeak, profileDownPeak:
+				switch {
+				case from == 0:
+					od[from][to] = peakMainShare / upper
+				case to == 0:
+					od[from][to] = peakCounterShare / upper
+				default:
+					od[from][to] = interfloor / (upper * (upper - 1))
+				}
+			default:
+				return nil, fmt.Errorf("unknown traffic profile %q", profile)
+			}
+		}
+	}
+
+	if profile == profileDownPeak {
+		// The reverse journeys of up-peak
+		for from := range od {
+			for to := from + 1; to < numFloors; to++ {
+				od[from][to], od[to][from] = od[to][from], od[from][to]
+			}
+		}
+	}
+
+	return od, nil
+}
+
+// loadODMatrix reads an origin/destination matrix from a JSON file holding an
+// array of rows, one per origin floor
+func loadODMatrix(path string, numFloors int) ([][]float64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read OD matrix: %w", err)
+	}
+
+	var od [][]float64
+	if err := json.Unmarshal(data, &od); err != nil {
+		return nil, fmt.Errorf("failed to parse OD matrix %s: %w", path, err)
+	}
+
+	if err := validateODMatrix(od, numFloors); err != nil {
+		return nil, fmt.Errorf("invalid OD matrix %s: %w", path, err)
+	}
+
+	return od, nil
+}
+
+func validateODMatrix(od [][]float64, numFloors int) error {
+	if len(od) != numFloors {
+		return fmt.Errorf("expected %d rows, got %d", numFloors, len(od))
+	}
+
+	var total float64
+	for from := range od {
+		if len(od[from]) != numFloors {
+			return fmt.Errorf("row %d has %d columns, expected %d", from, len(od[from]), numFloors)
+		}
+		for to, w := range od[from] {
+			if w < 0 {
+				return fmt.Errorf("negative weight %v from %d to %d", w, from, to)
+			}
+			if from != to {
+				total += w
+			}
+		}
+	}
+
+	if total <= 0 {
+		return fmt.Errorf("no journeys between different floors")
+	}
+
+	return nil
+}
+
+// generateArrivals draws passengers arriving as a Poisson process with the
+// given rate, their journeys weighted by the OD matrix
+func generateArrivals(rng *rand.Rand, od [][]float64, perMinute float64, duration time.Duration) []passenger {
+	type journey struct{ from, to int }
+
+	var journeys []journey
+	var cumulative []float64
+	var total float64
+	for from := range od {
+		for to, w := range od[from] {
+			if from == to || w <= 0 {
+				continue
+			}
+			total += w
+			journeys = append(journeys, journey{from, to})
+			cumulative = append(cumulative, total)
+		}
+	}
+
+	var arrivals []passenger
+	mean := float64(time.Minute) / perMinute
+	for at := time.Duration(rng.ExpFloat64() * mean); at < duration; at += time.Duration(rng.ExpFloat64() * mean) {
+		x := rng.Float64() * total
+		i := 0
+		for i < len(cumulative)-1 && cumulative[i] <= x {
+			i++
+		}
+		arrivals = append(arrivals, passenger{
+			from:    journeys[i].from,
+			to:      journeys[i].to,
+			arrival: at,
+		})
+	}
+
+	return arrivals
+}
@@ -0,0 +1,131 @@
+// Package config loads the node configuration file
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Dispatch modes. Collective control takes up and down hall calls, destination
+// dispatch takes the floor each passenger is going to.
+const (
+	DispatchCollective  = "collective"
+	DispatchDestination = "destination"
+)
+
+// Config holds the settings shared by every node in the cluster. Durations are
+// given in milliseconds so the file stays readable.
+type Config struct {
+	NumFloors          int     `json:"numFloors"`
+	DoorOpenDurationMs int     `json:"doorOpenDurationMs"`
+	TravelTimeMs       int     `json:"travelTimeMs"` // between two adjacent floors
+	SyncPort           int     `json:"syncPort"`
+	LoadCapacity       int     `json:"loadCapacity"` // 0 disables the simulated load sensor
+	FullLoadThreshold  float64 `json:"fullLoadThreshold"`
+	StatusPeriodMs     int     `json:"statusPeriodMs"`
+	Assigner           string  `json:"assigner"`      // hall call assignment strategy
+	HRAExecutable      string  `json:"hraExecutable"` // used by the hra-exec strategy
+	// AssignmentMarginMs is how much sooner another car must finish before
+	// it may take over a hall call. 0 disables the hysteresis.
+	AssignmentMarginMs int    `json:"assignmentMarginMs"`
+	DispatchMode       string `json:"dispatchMode"`
+}
+
+// Default returns the configuration used when no file is given
+func Default() *Config {
+	return &Config{
+		NumFloors:          4,
+		DoorOpenDurationMs: 3000,
+		TravelTimeMs:       2000,
+		SyncPort:           30000,
+		LoadCapacity:       0,
+		FullLoadThreshold:  0.8,
+		StatusPeriodMs:     5000,
+		Assigner:           "time-to-idle",
+		AssignmentMarginMs: 2000,
+		DispatchMode:       DispatchCollective,
+	}
+}
+
+// Load reads the configuration at path on top of the defaults. An empty path
+// gives the defaults.
+func Load(path string) (*Config, error) {
+	cfg := Default()
+	if path == "" {
+		return cfg, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse config %s: %w", path, err)
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config %s: %w", path, err)
+	}
+
+	return cfg, nil
+}
+
+// Validate does sanity check on the configuration
+func (c *Config) Validate() error {
+	if c.NumFloors < 2 {
+		return fmt.Errorf("numFloors must be at least 2, got %d", c.NumFloors)
+	}
+
+	if c.DoorOpenDurationMs <= 0 {
+		return fmt.Errorf("doorOpenDurationMs must be positive, got %d", c.DoorOpenDurationMs)
+	}
+
+	if c.TravelTimeMs <= 0 {
+		return fmt.Errorf("travelTimeMs must be positive, got %d", c.TravelTimeMs)
+	}
+
+	if c.SyncPort <= 0 || c.SyncPort > 65535 {
+		return fmt.Errorf("syncPort %d is out of range", c.SyncPort)
+	}
+
+	if c.LoadCapacity < 0 {
+		return fmt.Errorf("loadCapacity must not be negative, got %d", c.LoadCapacity)
+	}
+
+	if c.FullLoadThreshold <= 0 || c.FullLoadThreshold > 1 {
+		return fmt.Errorf("fullLoadThreshold must be in (0, 1], got %v", c.FullLoadThreshold)
+	}
+
+	if c.AssignmentMarginMs < 0 {
+		return fmt.Errorf("assignmentMarginMs must not be negative, got %d", c.AssignmentMarginMs)
+	}
+
+	if c.DispatchMode != DispatchCollective && c.DispatchMode != DispatchDestination {
+		return fmt.Errorf("dispatchMode must be %q or %q, got %q", DispatchCollective, DispatchDestination, c.DispatchMode)
+	}
+
+	if c.StatusPeriodMs <= 0 {
+		return fmt.Errorf("statusPeriodMs must be positive, got %d", c.StatusPeriodMs)
+	}
+
+	return nil
+}
+
+func (c *Config) DoorOpenDuration() time.Duration {
+	return time.Duration(c.DoorOpenDurationMs) * time.Millisecond
+}
+
+func (c *Config) TravelTime() time.Duration {
+	return time.Duration(c.TravelTimeMs) * time.Millisecond
+}
+
+func (c *Config) AssignmentMargin() time.Duration {
+	return time.Duration(c.AssignmentMarginMs) * time.Millisecond
+}
+
+func (c *Config) StatusPeriod() time.Duration {
+	return time.Duration(c.StatusPeriodMs) * time.Millisecond
+}
@@ -11,6 +11,7 @@ import (
 type (
 	Behavior  int
 	DoorState int
+	Mode      int
 )
 
 const (
@@ -27,6 +28,23 @@ const (
 	DSOpen
 )
 
+// MIndependent is independent service (attendant) mode: the car only serves
+// cab calls and keeps its door open until a cab call is entered.
+const (
+	MNormal Mode = iota
+	MIndependent
+)
+
+func (m Mode) String() string {
+	switch m {
+	case MNormal:
+		return "NORMAL"
+	case MIndependent:
+		return "INDEPENDENT"
+	}
+	return "UNKNOWN"
+}
+
 func (d DoorState) String() string {
 	switch d {
 	case DSStuck:
@@ -55,6 +73,11 @@ func (b Behavior) String() string {
 	return "UNKNOWN"
 }
 
+const (
+	DefaultDoorOpenDuration = 3 * time.Second
+	DoorClosingDuration     = 500 * time.Millisecond
+)
+
 type Order struct {
 	Floor int
 	RType elevio.ButtonType
@@ -67,14 +90,26 @@ type ElevState struct {
 	PrevFloor int
 	Dir       elevio.MotorDirection
 	Behavior  Behavior
+	Mode      Mode
+	Door      DoorState
 	Orders    [4][3]bool
+	// Obstructed is the last reading of the obstruction switch
+	Obstructed bool
+	// DoorOpenDuration is how long the door stays open at a stop
+	DoorOpenDuration time.Duration
+	doorTimer        *time.Timer
+	// Served, if set, is called for every order the car has served
+	Served func(order elevio.ButtonEvent)
+	// FullLoadThreshold is the fraction of the load sensor's capacity
+	// above which hall calls are bypassed
+	FullLoadThreshold float64
 }
 
 func (e *ElevState) ClearAllOrders() {
 	for f := range e.Orders {
 		for b := range e.Orders[f] {
 			e.Orders[f][b] = false
-			e.io.SetButtonLamp(elevio.ButtonType(b), f, false)
+			e.setOrderLamp(elevio.ButtonType(b), f, false)
 		}
 	}
 }
@@ -85,6 +120,9 @@ func (e *ElevState) SetDir(dir elevio.MotorDirection) {
 }
 
 func NewElevState(initFloor int, orders [4][3]bool, io elevio.ElevatorDriver) *ElevState {
+	doorTimer := time.NewTimer(DefaultDoorOpenDuration)
+	doorTimer.Stop()
+
 	return &ElevState{
 		io:        io,
 		Target:    Order{-1, elevio.Cab},
@@ -92,13 +130,25 @@ func NewElevState(initFloor int, orders [4][3]bool, io elevio.ElevatorDriver) *E
 		PrevFloor: -1,
 		Dir:       elevio.Stop,
 		Behavior:  BIdle,
+		Mode:      MNormal,
+		Door:      DSClosed,
 		Orders:    orders,
+
+		DoorOpenDuration:  DefaultDoorOpenDuration,
+		doorTimer:         doorTimer,
+		FullLoadThreshold: DefaultFullLoadThreshold,
 	}
 }
 
+// DoorTimeout fires when the door has been open, or closing, long enough.
+// The owner of the state machine should call OnDoorTimeout when it does.
+func (e *ElevState) DoorTimeout() <-chan time.Time {
+	return e.doorTimer.C
+}
+
 func (e *ElevState) String() string {
-	return fmt.Sprintf("{ Target: %+v, CurrFloor: %d, PrevFloor: %d, Dir: %v, Behavior: %s, Orders: %+v }",
-		e.Target, e.CurrFloor, e.PrevFloor, e.Dir, e.Behavior, e.Orders)
+	return fmt.Sprintf("{ Target: %+v, CurrFloor: %d, PrevFloor: %d, Dir: %v, Behavior: %s, Mode: %s, Door: %s, Orders: %+v }",
+		e.Target, e.CurrFloor, e.PrevFloor, e.Dir, e.Behavior, e.Mode, e.Door, e.Orders)
 }
 
 // ---- Event Handlers ----//
@@ -111,11 +161,30 @@ func (e *ElevState) OnInitBetweenFloors() {
 
 func (e *ElevState) OnOrderRequest(order elevio.ButtonEvent) {
 	fmt.Printf("[ORDER] %+v\n", order)
-	e.io.SetButtonLamp(order.Button, order.Floor, true)
-	e.Orders[order.Floor][order.Button] = true
+	if order.Button != elevio.Cab && !AcceptsHallCalls(e) {
+		fmt.Printf("Ignoring hall call in %s mode\n", e.Mode)
+		return
+	}
+
+	if e.Mode == MIndependent && e.Behavior == BDoorOpen {
+		e.onIndependentCabCall(order)
+		return
+	}
+
+	atThisFloor := order.Floor == e.CurrFloor && isServiceable(e, order.Button)
+
 	switch e.Behavior {
 	case BIdle:
-		// Mark as active
+		if atThisFloor {
+			// Serve it on the spot, there is no trip to make. The door is
+			// opened first, so that an order handed in once this one is
+			// served waits for the door to close.
+			e.openDoor()
+			e.clearOrder(order)
+			break
+		}
+
+		e.addOrder(order)
 
 		// Set Target floor
 		e.Target.RType = order.Button
@@ -125,8 +194,21 @@ func (e *ElevState) OnOrderRequest(order elevio.ButtonEvent) {
 
 		e.io.SetMotorDirection(e.Dir)
 
-	case BMoving:
 	case BDoorOpen:
+		if atThisFloor {
+			// Reopens a closing door, or keeps an open one open
+			e.openDoor()
+			e.clearOrder(order)
+			break
+		}
+
+		// Picked up when the door has closed
+		e.addOrder(order)
+
+	case BMoving:
+		// The car has left CurrFloor, so even a request there needs a trip.
+		// ShouldStop picks it up on arrival.
+		e.addOrder(order)
 	}
 
 	fmt.Printf("State: %v\n", e)
@@ -148,20 +230,56 @@ func (e *ElevState) OnNewFloorArrival(floor int) {
 	case BMoving:
 		if ShouldStop(e) {
 			// stop
-			e.Dir = elevio.Stop
-			e.io.SetMotorDirection(e.Dir)
+			e.SetDir(elevio.Stop)
 			ClearAtCurrentFloor(e)
 			e.SetAllLights()
-			e.io.SetDoorOpenLamp(true)
-			time.Sleep(3 * time.Second)
-			e.io.SetDoorOpenLamp(false)
-			e.Dir, e.Behavior = ChooseDirection(e)
+			if e.Mode == MIndependent && !HasOrders(e) {
+				e.openDoorUntilCabCall()
+			} else {
+				e.openDoor()
+			}
+		}
+	}
+}
+
+// OnDoorTimeout closes the door in two steps so that a request at this floor
+// can still reopen it while it is closing. The car departs once it is closed.
+func (e *ElevState) OnDoorTimeout() {
+	fmt.Printf("[DOOR] %v\n", e.Door)
+	if e.Behavior != BDoorOpen {
+		return
+	}
+
+	switch e.Door {
+	case DSOpen:
+		if e.Mode == MIndependent && !HasOrders(e) {
+			// Nowhere to go until a cab call comes in
+			e.openDoorUntilCabCall()
+			return
 		}
+		e.closeDoor()
+
+	case DSClosing:
+		e.Door = DSClosed
+		e.Dir = elevio.Stop
+		e.Dir, e.Behavior = ChooseDirection(e)
+		e.io.SetMotorDirection(e.Dir)
 	}
+
+	fmt.Printf("State: %v\n", e)
+}
+
+// OnOrderWithdrawn drops an order the car no longer has to serve, such as a
+// hall call that has been given to another elevator
+func (e *ElevState) OnOrderWithdrawn(order elevio.ButtonEvent) {
+	fmt.Printf("[WITHDRAWN] %+v\n", order)
+	e.Orders[order.Floor][order.Button] = false
+	e.setOrderLamp(order.Button, order.Floor, false)
 }
 
 func (e *ElevState) OnObstructionSignal(obstructed bool) {
 	fmt.Printf("[OBSTR] %+v\n", obstructed)
+	e.Obstructed = obstructed
 	if obstructed {
 		e.io.SetMotorDirection(elevio.Stop)
 	} else {
@@ -175,8 +293,112 @@ func (e *ElevState) OnStopSignal(stop bool) {
 	fmt.Printf("[STOP] %+v\n", stop)
 	for f := range e.Orders {
 		for b := range e.Orders[f] {
-			e.io.SetButtonLamp(elevio.ButtonType(b), f, false)
+			e.setOrderLamp(elevio.ButtonType(b), f, false)
+		}
+	}
+
+	fmt.Printf("State: %v\n", e)
+}
+
+// OnIndependentServiceSwitch enters or leaves independent service mode. Hall
+// orders are dropped on entry so the other cars can pick them up.
+func (e *ElevState) OnIndependentServiceSwitch(on bool) {
+	fmt.Printf("[INDEPENDENT] %+v\n", on)
+	if on == (e.Mode == MIndependent) {
+		return
+	}
+
+	if !on {
+		e.Mode = MNormal
+		if e.Behavior == BDoorOpen {
+			e.openDoor()
 		}
+		fmt.Printf("State: %v\n", e)
+		return
+	}
+
+	e.Mode = MIndependent
+	ClearHallOrders(e)
+	e.SetAllLights()
+
+	if e.Behavior == BIdle && e.CurrFloor != -1 {
+		e.openDoorUntilCabCall()
+	}
+
+	fmt.Printf("State: %v\n", e)
+}
+
+// openDoorUntilCabCall parks the car with the door open. In independent mode
+// the door is only closed again by a cab call.
+func (e *ElevState) openDoorUntilCabCall() {
+	e.doorTimer.Stop()
+	e.io.SetDoorOpenLamp(true)
+	e.Door = DSOpen
+	e.Behavior = BDoorOpen
+}
+
+// closeDoor starts closing the door, unless it is obstructed, in which case
+// it tries again after DoorOpenDuration
+func (e *ElevState) closeDoor() {
+	if e.Obstructed {
+		e.doorTimer.Reset(e.DoorOpenDuration)
+		return
+	}
+	e.Door = DSClosing
+	e.io.SetDoorOpenLamp(false)
+	e.doorTimer.Reset(DoorClosingDuration)
+}
+
+// openDoor opens the door, or keeps it open, for DoorOpenDuration
+func (e *ElevState) openDoor() {
+	e.io.SetDoorOpenLamp(true)
+	e.Door = DSOpen
+	e.Behavior = BDoorOpen
+	e.doorTimer.Reset(e.DoorOpenDuration)
+}
+
+func (e *ElevState) addOrder(order elevio.ButtonEvent) {
+	e.Orders[order.Floor][order.Button] = true
+	e.setOrderLamp(order.Button, order.Floor, true)
+}
+
+func (e *ElevState) clearOrder(order elevio.ButtonEvent) {
+	e.Orders[order.Floor][order.Button] = false
+	e.setOrderLamp(order.Button, order.Floor, false)
+	e.notifyServed(order)
+}
+
+// setOrderLamp lights the lamp of a cab order. Hall lamps are lit from the
+// shared worldview, once every elevator knows of the call.
+func (e *ElevState) setOrderLamp(button elevio.ButtonType, floor int, value bool) {
+	if button == elevio.Cab {
+		e.io.SetButtonLamp(button, floor, value)
+	}
+}
+
+func (e *ElevState) notifyServed(order elevio.ButtonEvent) {
+	if e.Served != nil {
+		e.Served(order)
+	}
+}
+
+// onIndependentCabCall takes a cab call while the car stands with its door
+// open in independent mode. A call elsewhere closes the door the usual way,
+// and the car departs once it is closed.
+func (e *ElevState) onIndependentCabCall(order elevio.ButtonEvent) {
+	if order.Floor == e.CurrFloor {
+		// Already here, nothing to travel to
+		e.openDoor()
+		e.clearOrder(order)
+		return
+	}
+
+	e.addOrder(order)
+	e.Target.RType = order.Button
+	e.Target.Floor = order.Floor
+
+	if e.Door == DSOpen {
+		e.closeDoor()
 	}
 
 	fmt.Printf("State: %v\n", e)
@@ -186,7 +408,7 @@ func (e *ElevState) SetAllLights() {
 	for f := range e.Orders {
 		for b := range e.Orders[f] {
 			fmt.Printf("F: %v, B: %v, on: %v ", elevio.ButtonType(b), f, e.Orders[f][b])
-			e.io.SetButtonLamp(elevio.ButtonType(b), f, e.Orders[f][b])
+			e.setOrderLamp(elevio.ButtonType(b), f, e.Orders[f][b])
 		}
 		fmt.Println()
 	}
@@ -0,0 +1,322 @@
+package elevator
+
+import (
+	"testing"
+
+	elevio "github.com/Mosazghi/elevator-ttk4145/internal/hw"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeDriver records the outputs the state machine sets
+type fakeDriver struct {
+	motor    elevio.MotorDirection
+	doorLamp bool
+	lamps    [4][3]bool
+}
+
+func (d *fakeDriver) ReadInitialButtons() [4][3]bool              { return [4][3]bool{} }
+func (d *fakeDriver) SetMotorDirection(dir elevio.MotorDirection) { d.motor = dir }
+func (d *fakeDriver) SetButtonLamp(button elevio.ButtonType, floor int, value bool) {
+	d.lamps[floor][button] = value
+}
+func (d *fakeDriver) SetFloorIndicator(floor int)                        {}
+func (d *fakeDriver) SetDoorOpenLamp(value bool)                         { d.doorLamp = value }
+func (d *fakeDriver) SetStopLamp(value bool)                             {}
+func (d *fakeDriver) GetButton(button elevio.ButtonType, floor int) bool { return false }
+func (d *fakeDriver) GetFloor() int                                      { return -1 }
+func (d *fakeDriver) GetStop() bool                                      { return false }
+func (d *fakeDriver) GetTotalFloors() int                                { return 4 }
+func (d *fakeDriver) GetObstruction() bool                               { return false }
+func (d *fakeDriver) PollButtons(receiver chan<- elevio.ButtonEvent)     {}
+func (d *fakeDriver) PollFloorSensor(receiver chan<- int)                {}
+func (d *fakeDriver) PollStopButton(receiver chan<- bool)                {}
+func (d *fakeDriver) PollObstructionSwitch(receiver chan<- bool)         {}
+
+func newTestElevator(floor int) (*ElevState, *fakeDriver) {
+	drv := &fakeDriver{}
+	return NewElevState(floor, [4][3]bool{}, drv), drv
+}
+
+var sameFloorButtons = []elevio.ButtonType{elevio.HallUp, elevio.HallDown, elevio.Cab}
+
+// A request at the floor of an idle car opens the door without a trip
+func TestOnOrderRequest_SameFloor_Idle(t *testing.T) {
+	for _, b := range sameFloorButtons {
+		t.Run(b.String(), func(t *testing.T) {
+			e, drv := newTestElevator(1)
+
+			e.OnOrderRequest(elevio.ButtonEvent{Floor: 1, Button: b})
+
+			assert.Equal(t, BDoorOpen, e.Behavior)
+			assert.Equal(t, DSOpen, e.Door)
+			assert.True(t, drv.doorLamp, "door lamp should be on")
+			assert.Equal(t, elevio.Stop, drv.motor, "car should not move")
+			assert.False(t, e.Orders[1][b], "order should be cleared immediately")
+			assert.False(t, drv.lamps[1][b], "button lamp should be off")
+		})
+	}
+}
+
+// A request at the floor while the door is open keeps it open
+func TestOnOrderRequest_SameFloor_DoorOpen(t *testing.T) {
+	for _, b := range sameFloorButtons {
+		t.Run(b.String(), func(t *testing.T) {
+			e, drv := newTestElevator(2)
+			e.openDoor()
+
+			e.OnOrderRequest(elevio.ButtonEvent{Floor: 2, Button: b})
+
+			assert.Equal(t, BDoorOpen, e.Behavior)
+			assert.Equal(t, DSOpen, e.Door)
+			assert.True(t, drv.doorLamp)
+			assert.False(t, e.Orders[2][b], "order should be cleared immediately")
+		})
+	}
+}
+
+// A request at the floor while the door is closing reopens it
+func TestOnOrderRequest_SameFloor_DoorClosing(t *testing.T) {
+	for _, b := range sameFloorButtons {
+		t.Run(b.String(), func(t *testing.T) {
+			e, drv := newTestElevator(2)
+			e.openDoor()
+			e.OnDoorTimeout()
+			require.Equal(t, DSClosing, e.Door)
+			require.False(t, drv.doorLamp)
+
+			e.OnOrderRequest(elevio.ButtonEvent{Floor: 2, Button: b})
+
+			assert.Equal(t, BDoorOpen, e.Behavior)
+			assert.Equal(t, DSOpen, e.Door, "door should reopen")
+			assert.True(t, drv.doorLamp)
+			assert.False(t, e.Orders[2][b], "order should be cleared immediately")
+
+			// The reopened door closes as usual and the car stays idle
+			e.OnDoorTimeout()
+			e.OnDoorTimeout()
+			assert.Equal(t, BIdle, e.Behavior)
+			assert.Equal(t, DSClosed, e.Door)
+		})
+	}
+}
+
+// An order handed in while the one at the floor is reported served waits
+// for the door to close
+func TestOnOrderRequest_SameFloor_OrderWhenServed(t *testing.T) {
+	e, drv := newTestElevator(1)
+	e.Served = func(order elevio.ButtonEvent) {
+		if order.Button == elevio.HallUp {
+			e.OnOrderRequest(elevio.ButtonEvent{Floor: 3, Button: elevio.Cab})
+		}
+	}
+
+	e.OnOrderRequest(elevio.ButtonEvent{Floor: 1, Button: elevio.HallUp})
+
+	assert.Equal(t, BDoorOpen, e.Behavior)
+	assert.Equal(t, DSOpen, e.Door)
+	assert.Equal(t, elevio.Stop, drv.motor, "car should not move with the door open")
+	assert.True(t, e.Orders[3][elevio.Cab])
+
+	e.OnDoorTimeout()
+	e.OnDoorTimeout()
+
+	assert.Equal(t, BMoving, e.Behavior)
+	assert.Equal(t, elevio.Up, drv.motor)
+}
+
+// A car that has left the floor must come back, so the order is kept
+func TestOnOrderRequest_SameFloor_Moving(t *testing.T) {
+	for _, b := range sameFloorButtons {
+		t.Run(b.String(), func(t *testing.T) {
+			e, drv := newTestElevator(1)
+			e.OnOrderRequest(elevio.ButtonEvent{Floor: 3, Button: elevio.Cab})
+			require.Equal(t, BMoving, e.Behavior)
+
+			e.OnOrderRequest(elevio.ButtonEvent{Floor: 1, Button: b})
+
+			assert.Equal(t, BMoving, e.Behavior)
+			assert.Equal(t, elevio.Up, drv.motor, "car should keep moving")
+			assert.True(t, e.Orders[1][b], "order should be kept")
+			assert.Equal(t, b == elevio.Cab, drv.lamps[1][b], "only cab lamps are lit by the car")
+		})
+	}
+}
+
+// A cab call in independent mode at the floor leaves the door open
+func TestOnOrderRequest_SameFloor_Independent(t *testing.T) {
+	e, drv := newTestElevator(0)
+	e.OnIndependentServiceSwitch(true)
+	require.Equal(t, BDoorOpen, e.Behavior)
+
+	e.OnOrderRequest(elevio.ButtonEvent{Floor: 0, Button: elevio.Cab})
+
+	assert.Equal(t, BDoorOpen, e.Behavior)
+	assert.True(t, drv.doorLamp)
+	assert.False(t, e.Orders[0][elevio.Cab])
+}
+
+// A request elsewhere while the door is open departs once the door has closed
+func TestOnOrderRequest_OtherFloor_DoorOpen(t *testing.T) {
+	e, drv := newTestElevator(1)
+	e.openDoor()
+
+	e.OnOrderRequest(elevio.ButtonEvent{Floor: 3, Button: elevio.HallDown})
+
+	assert.Equal(t, BDoorOpen, e.Behavior, "car should not leave with the door open")
+	assert.True(t, e.Orders[3][elevio.HallDown])
+
+	e.OnDoorTimeout()
+	e.OnDoorTimeout()
+
+	assert.Equal(t, BMoving, e.Behavior)
+	assert.Equal(t, elevio.Up, drv.motor)
+}
+
+// An obstructed door does not start closing
+func TestOnDoorTimeout_Obstructed(t *testing.T) {
+	e, drv := newTestElevator(1)
+	e.openDoor()
+	e.OnObstructionSignal(true)
+
+	e.OnDoorTimeout()
+
+	assert.Equal(t, DSOpen, e.Door)
+	assert.True(t, drv.doorLamp)
+}
+
+// A full car passes hall calls but stops for cab calls
+func TestShouldStop_FullLoad(t *testing.T) {
+	drv := elevio.NewLoadSimDriver(&fakeDriver{}, 10)
+	e := NewElevState(1, [4][3]bool{}, drv)
+	e.Dir = elevio.Up
+	e.Orders[1][elevio.HallUp] = true
+	e.Orders[3][elevio.Cab] = true
+
+	assert.True(t, ShouldStop(e), "empty car should stop for hall call")
+
+	drv.Board(9)
+	assert.False(t, ShouldStop(e), "full car should pass hall call")
+
+	e.Orders[1][elevio.Cab] = true
+	assert.True(t, ShouldStop(e), "full car should stop for cab call")
+
+	ClearAtCurrentFloor(e)
+	assert.True(t, e.Orders[1][elevio.HallUp], "full car should leave the hall call")
+}
+
+// requireHeldOpen checks that the car stands with its door open and no door
+// timeout closes it
+func requireHeldOpen(t *testing.T, e *ElevState, drv *fakeDriver) {
+	t.Helper()
+	e.OnDoorTimeout()
+	require.Equal(t, BDoorOpen, e.Behavior)
+	require.Equal(t, DSOpen, e.Door)
+	require.True(t, drv.doorLamp)
+	require.Equal(t, elevio.Stop, drv.motor)
+}
+
+// Switched on while idle, the car opens its door and waits for a cab call
+func TestOnIndependentServiceSwitch_Idle(t *testing.T) {
+	e, drv := newTestElevator(1)
+	e.Orders[2][elevio.HallDown] = true
+
+	e.OnIndependentServiceSwitch(true)
+
+	assert.Equal(t, MIndependent, e.Mode)
+	assert.False(t, e.Orders[2][elevio.HallDown], "hall orders should be dropped")
+	requireHeldOpen(t, e, drv)
+}
+
+// Switched on while moving, the car serves its cab calls and then waits
+func TestOnIndependentServiceSwitch_Moving(t *testing.T) {
+	e, drv := newTestElevator(0)
+	e.OnOrderRequest(elevio.ButtonEvent{Floor: 3, Button: elevio.Cab})
+	e.OnOrderRequest(elevio.ButtonEvent{Floor: 2, Button: elevio.HallUp})
+	require.Equal(t, BMoving, e.Behavior)
+
+	e.OnIndependentServiceSwitch(true)
+
+	assert.Equal(t, BMoving, e.Behavior)
+	assert.False(t, e.Orders[2][elevio.HallUp])
+
+	e.OnNewFloorArrival(2)
+	assert.Equal(t, BMoving, e.Behavior, "dropped hall call should be passed")
+
+	e.OnNewFloorArrival(3)
+	requireHeldOpen(t, e, drv)
+}
+
+// Switched on with the door open, the car keeps it open once it would close
+func TestOnIndependentServiceSwitch_DoorOpen(t *testing.T) {
+	e, drv := newTestElevator(1)
+	e.openDoor()
+
+	e.OnIndependentServiceSwitch(true)
+
+	requireHeldOpen(t, e, drv)
+}
+
+// A cab call closes the held door the usual way before the car departs
+func TestOnOrderRequest_Independent_ClosesDoor(t *testing.T) {
+	e, drv := newTestElevator(1)
+	e.OnIndependentServiceSwitch(true)
+
+	e.OnOrderRequest(elevio.ButtonEvent{Floor: 3, Button: elevio.Cab})
+
+	assert.Equal(t, DSClosing, e.Door)
+	assert.False(t, drv.doorLamp)
+	assert.Equal(t, elevio.Stop, drv.motor, "car should not leave before the door has closed")
+
+	e.OnDoorTimeout()
+
+	assert.Equal(t, BMoving, e.Behavior)
+	assert.Equal(t, elevio.Up, drv.motor)
+}
+
+// Cab calls already queued are served one after the other without waiting
+// for another press
+func TestOnNewFloorArrival_Independent_QueuedCabCall(t *testing.T) {
+	e, drv := newTestElevator(0)
+	e.OnIndependentServiceSwitch(true)
+	e.OnOrderRequest(elevio.ButtonEvent{Floor: 2, Button: elevio.Cab})
+	e.OnOrderRequest(elevio.ButtonEvent{Floor: 3, Button: elevio.Cab})
+	e.OnDoorTimeout()
+	require.Equal(t, BMoving, e.Behavior)
+
+	e.OnNewFloorArrival(2)
+	assert.Equal(t, BDoorOpen, e.Behavior)
+	assert.False(t, e.Orders[2][elevio.Cab])
+
+	e.OnDoorTimeout()
+	e.OnDoorTimeout()
+	assert.Equal(t, BMoving, e.Behavior, "car should go on to the queued cab call")
+	assert.Equal(t, elevio.Up, drv.motor)
+
+	e.OnNewFloorArrival(3)
+	requireHeldOpen(t, e, drv)
+}
+
+// An obstructed held door stays open on a cab call until it is cleared
+func TestOnOrderRequest_Independent_Obstructed(t *testing.T) {
+	e, drv := newTestElevator(1)
+	e.OnIndependentServiceSwitch(true)
+	e.OnObstructionSignal(true)
+
+	e.OnOrderRequest(elevio.ButtonEvent{Floor: 3, Button: elevio.Cab})
+
+	assert.Equal(t, DSOpen, e.Door)
+	assert.True(t, drv.doorLamp)
+	assert.Equal(t, elevio.Stop, drv.motor, "obstructed car should not depart")
+
+	e.OnDoorTimeout()
+	assert.Equal(t, DSOpen, e.Door)
+	assert.Equal(t, elevio.Stop, drv.motor)
+
+	e.OnObstructionSignal(false)
+	e.OnDoorTimeout()
+	e.OnDoorTimeout()
+
+	assert.Equal(t, BMoving, e.Behavior)
+	assert.Equal(t, elevio.Up, drv.motor)
+}
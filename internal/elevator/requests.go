@@ -6,6 +6,36 @@ import (
 	elevio "github.com/Mosazghi/elevator-ttk4145/internal/hw"
 )
 
+// DefaultFullLoadThreshold is the fraction of capacity above which the car
+// bypasses hall calls
+const DefaultFullLoadThreshold = 0.8
+
+// AcceptsHallCalls reports whether the car takes part in hall call service
+func AcceptsHallCalls(e *ElevState) bool {
+	return e.Mode != MIndependent
+}
+
+// IsFull reports whether the car's load is above the full-load threshold.
+// Drivers without a load sensor are never full.
+func IsFull(e *ElevState) bool {
+	sensor, ok := e.io.(elevio.LoadSensor)
+	if !ok || sensor.GetCapacity() <= 0 {
+		return false
+	}
+
+	return float64(sensor.GetLoad()) >= e.FullLoadThreshold*float64(sensor.GetCapacity())
+}
+
+// ServesHallCalls reports whether the car currently stops for hall calls
+func ServesHallCalls(e *ElevState) bool {
+	return AcceptsHallCalls(e) && !IsFull(e)
+}
+
+// isServiceable reports whether an order of type b moves the car right now
+func isServiceable(e *ElevState, b elevio.ButtonType) bool {
+	return b == elevio.Cab || ServesHallCalls(e)
+}
+
 func HasOrders(e *ElevState) bool {
 	for f := range e.Orders {
 		for b := range e.Orders[f] {
@@ -20,7 +50,7 @@ func HasOrders(e *ElevState) bool {
 func HasOrdersAbove(e *ElevState) bool {
 	for f := e.CurrFloor + 1; f < len(e.Orders); f++ {
 		for b := range e.Orders[f] {
-			if e.Orders[f][b] {
+			if e.Orders[f][b] && isServiceable(e, elevio.ButtonType(b)) {
 				return true
 			}
 		}
@@ -31,7 +61,7 @@ func HasOrdersAbove(e *ElevState) bool {
 func HasOrdersBelow(e *ElevState) bool {
 	for f := 0; f < e.CurrFloor; f++ {
 		for b := range e.Orders[f] {
-			if e.Orders[f][b] {
+			if e.Orders[f][b] && isServiceable(e, elevio.ButtonType(b)) {
 				return true
 			}
 		}
@@ -40,6 +70,13 @@ func HasOrdersBelow(e *ElevState) bool {
 }
 
 func ShouldStop(e *ElevState) bool {
+	if !ServesHallCalls(e) {
+		return e.Orders[e.CurrFloor][elevio.Cab] ||
+			(e.Dir == elevio.Down && !HasOrdersBelow(e)) ||
+			(e.Dir == elevio.Up && !HasOrdersAbove(e)) ||
+			e.Dir == elevio.Stop
+	}
+
 	switch e.Dir {
 	case elevio.Down:
 		return e.Orders[e.CurrFloor][elevio.HallDown] ||
@@ -74,14 +111,29 @@ func ChooseDirection(e *ElevState) (elevio.MotorDirection, Behavior) {
 }
 
 func ClearAtCurrentFloor(e *ElevState) {
-	e.Orders[e.CurrFloor][elevio.Cab] = false
+	clearAtCurrentFloor(e, elevio.Cab)
+
+	// A full car leaves the hall calls for someone who can take them
+	if !ServesHallCalls(e) {
+		return
+	}
+
+	clearAtCurrentFloor(e, elevio.HallUp)
+	clearAtCurrentFloor(e, elevio.HallDown)
+}
 
-	if e.Orders[e.CurrFloor][elevio.HallUp] {
-		e.Orders[e.CurrFloor][elevio.HallUp] = false
+func clearAtCurrentFloor(e *ElevState, b elevio.ButtonType) {
+	if e.Orders[e.CurrFloor][b] {
+		e.Orders[e.CurrFloor][b] = false
+		e.notifyServed(elevio.ButtonEvent{Floor: e.CurrFloor, Button: b})
 	}
+}
 
-	if e.Orders[e.CurrFloor][elevio.HallDown] {
-		e.Orders[e.CurrFloor][elevio.HallDown] = false
+// ClearHallOrders drops every hall order held by the car
+func ClearHallOrders(e *ElevState) {
+	for f := range e.Orders {
+		e.Orders[f][elevio.HallUp] = false
+		e.Orders[f][elevio.HallDown] = false
 	}
 }
 
@@ -0,0 +1,42 @@
+package elevio
+
+import "fmt"
+
+// DestinationPanel simulates the hall terminals of destination dispatch, where
+// passengers key in the floor they want instead of pressing up or down
+type DestinationPanel struct {
+	numFloors int
+	presses   chan ButtonEvent
+}
+
+func NewDestinationPanel(numFloors int) *DestinationPanel {
+	return &DestinationPanel{
+		numFloors: numFloors,
+		presses:   make(chan ButtonEvent, 16),
+	}
+}
+
+// Press registers a passenger at floor from going to floor to
+func (p *DestinationPanel) Press(from, to int) error {
+	if from < 0 || from >= p.numFloors || to < 0 || to >= p.numFloors {
+		return fmt.Errorf("floors must be in [0, %d), got %d -> %d", p.numFloors, from, to)
+	}
+
+	if from == to {
+		return fmt.Errorf("destination %d is the passenger's own floor", to)
+	}
+
+	select {
+	case p.presses <- ButtonEvent{Floor: from, Button: HallDestination, Destination: to}:
+		return nil
+	default:
+		return fmt.Errorf("too many presses waiting")
+	}
+}
+
+// PollButtons forwards every press, like ElevatorDriver.PollButtons
+func (p *DestinationPanel) PollButtons(receiver chan<- ButtonEvent) {
+	for b := range p.presses {
+		receiver <- b
+	}
+}
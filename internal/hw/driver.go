@@ -60,11 +60,31 @@ const (
 	HallUp   ButtonType = 0
 	HallDown ButtonType = 1
 	Cab      ButtonType = 2
+	// HallDestination is a destination-dispatch terminal in the hall. It has
+	// no lamp and never reaches the local order matrix.
+	HallDestination ButtonType = 3
 )
 
+func (bt ButtonType) String() string {
+	switch bt {
+	case HallUp:
+		return "HallUp"
+	case HallDown:
+		return "HallDown"
+	case Cab:
+		return "Cab"
+	case HallDestination:
+		return "HallDestination"
+	default:
+		return "Unknown"
+	}
+}
+
 type ButtonEvent struct {
 	Floor  int
 	Button ButtonType
+	// Destination is the requested floor of a HallDestination event
+	Destination int
 }
 
 func NewElevIoDriver(addr string, numFloors int) *ElevIoDriver {
@@ -123,7 +143,7 @@ func (e *ElevIoDriver) PollButtons(receiver chan<- ButtonEvent) {
 			for b := ButtonType(0); b < 3; b++ {
 				v := e.GetButton(b, f)
 				if v != prev[f][b] && v != false {
-					receiver <- ButtonEvent{f, ButtonType(b)}
+					receiver <- ButtonEvent{Floor: f, Button: ButtonType(b)}
 				}
 				prev[f][b] = v
 			}
@@ -0,0 +1,58 @@
+package elevio
+
+import "sync"
+
+// LoadSensor is an optional extension of ElevatorDriver for drivers that can
+// weigh the car. Load and capacity are counted in passengers.
+type LoadSensor interface {
+	GetLoad() int
+	GetCapacity() int
+}
+
+// SimLoadSensor simulates a load-weighing device with a passenger count
+type SimLoadSensor struct {
+	mtx        sync.Mutex
+	passengers int
+	capacity   int
+}
+
+// LoadSimDriver adds a simulated load sensor to any ElevatorDriver
+type LoadSimDriver struct {
+	ElevatorDriver
+	*SimLoadSensor
+}
+
+func NewSimLoadSensor(capacity int) *SimLoadSensor {
+	return &SimLoadSensor{capacity: capacity}
+}
+
+func NewLoadSimDriver(drv ElevatorDriver, capacity int) *LoadSimDriver {
+	return &LoadSimDriver{
+		ElevatorDriver: drv,
+		SimLoadSensor:  NewSimLoadSensor(capacity),
+	}
+}
+
+func (s *SimLoadSensor) GetLoad() int {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return s.passengers
+}
+
+func (s *SimLoadSensor) GetCapacity() int {
+	return s.capacity
+}
+
+// Board lets n passengers in, limited by the capacity of the car
+func (s *SimLoadSensor) Board(n int) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.passengers = min(s.passengers+n, s.capacity)
+}
+
+// Alight lets n passengers out
+func (s *SimLoadSensor) Alight(n int) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.passengers = max(s.passengers-n, 0)
+}
@@ -11,6 +11,9 @@ import (
 const (
 	BROADCAST_IP = "255.255.255.255"
 	THE_ONE_PORT = 30000
+
+	// MaxDatagramSize is the largest payload a UDP datagram can carry
+	MaxDatagramSize = 65507
 )
 
 type UDPMessage struct {
@@ -34,7 +37,7 @@ func LocalIP() (string, error) {
 
 /* Creates a UDP socket with SO_REUSEADDR, SO_BROADCAST enabled.
 Allows multiple programs to bind to the same port. */
-func UDPCreateSocket() (*net.UDPConn, error) {
+func UDPCreateSocket(port int) (*net.UDPConn, error) {
 
 	s, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_DGRAM, syscall.IPPROTO_UDP)
 	if err != nil {
@@ -55,7 +58,7 @@ func UDPCreateSocket() (*net.UDPConn, error) {
 
 	// Bind to the the common port. addr.Addr = "0.0.0.0" by default
 	var addr syscall.SockaddrInet4
-	addr.Port = THE_ONE_PORT
+	addr.Port = port
 
 	err = syscall.Bind(s, &addr)
 	if err != nil {
@@ -79,12 +82,11 @@ func UDPCreateSocket() (*net.UDPConn, error) {
 	return udpConn, nil
 }
 
-/* Reads continuously from socket and passes the data to a channel */
+/* Reads continuously from socket and passes the data to a channel.
+Our own broadcasts are received too, it is up to the receiver to drop them:
+nodes on the same machine share an IP. */
 func UDPrx(connection *net.UDPConn, receiveChannel chan<- UDPMessage, errorChannel chan<- error) {
-	buffer := make([]byte, 1024)
-
-	localAddrStr, _ := LocalIP()
-	localAddr := net.ParseIP(localAddrStr)
+	buffer := make([]byte, MaxDatagramSize)
 
 	for {
 		n, remoteAddress, err := connection.ReadFromUDP(buffer)
@@ -96,11 +98,6 @@ func UDPrx(connection *net.UDPConn, receiveChannel chan<- UDPMessage, errorChann
 		data := make([]byte, n)
 		copy(data, buffer[:n])
 
-		if remoteAddress.IP.Equal(localAddr) {
-			//fmt.Println("[UDPrx] Echo spotted") // For testing purposes
-			continue
-		}
-
 		receiveChannel <- UDPMessage{
 			Data:    data,
 			Address: remoteAddress,
@@ -108,8 +105,8 @@ func UDPrx(connection *net.UDPConn, receiveChannel chan<- UDPMessage, errorChann
 	}
 }
 
-/* Initializes & runs the UDP network. */
-func UDPRunNetwork() (chan<- UDPMessage, <-chan UDPMessage, <-chan error, error) {
+/* Initializes & runs the UDP network on the given port. */
+func UDPRunNetwork(port int) (chan<- UDPMessage, <-chan UDPMessage, <-chan error, error) {
 
 
 	rxChan := make(chan UDPMessage, 20)
@@ -117,7 +114,7 @@ func UDPRunNetwork() (chan<- UDPMessage, <-chan UDPMessage, <-chan error, error)
 	errChan := make(chan error, 10)
 
 
-	conn, err := UDPCreateSocket()
+	conn, err := UDPCreateSocket(port)
 	if err != nil {
 		return nil, nil, nil, fmt.Errorf("Failed to create socket: %v", err)
 	}
@@ -126,7 +123,7 @@ func UDPRunNetwork() (chan<- UDPMessage, <-chan UDPMessage, <-chan error, error)
 
 	broadcastAddr := &net.UDPAddr{
 		IP:   net.ParseIP(BROADCAST_IP),
-		Port: THE_ONE_PORT,
+		Port: port,
 	}
 
 	// Broadcast
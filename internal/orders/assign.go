@@ -0,0 +1,84 @@
+package orders
+
+import (
+	"slices"
+
+	statesync "github.com/Mosazghi/elevator-ttk4145/internal/sync"
+)
+
+// TimeToIdleAssigner gives each hall call to the elevator that, with the call
+// added, becomes idle first. Ties go to the lowest elevator ID, so every node
+// computes the same assignment from the same worldview.
+type TimeToIdleAssigner struct {
+	Timing Timing
+}
+
+// Assign returns the hall calls each elevator should serve, keyed by elevator
+// ID. Every given elevator gets a matrix, possibly empty.
+func (a *TimeToIdleAssigner) Assign(hallCalls [][2]statesync.HallCallPairState, states []statesync.RemoteElevatorState) map[int][][2]bool {
+	candidates := sortedCandidates(states)
+	result := emptyAssignment(len(hallCalls), states)
+
+	// The calls given out so far, in the form TimeToIdle counts as an
+	// elevator's existing load
+	given := make([][2]statesync.HallCallPairState, len(hallCalls))
+
+	for _, call := range activeHallCalls(hallCalls) {
+		best := -1
+		var bestCost int64
+		for i := range candidates {
+			cost := int64(TimeToIdle(&candidates[i], given, call, a.Timing))
+			if best == -1 || cost < bestCost {
+				best, bestCost = i, cost
+			}
+		}
+
+		if best == -1 {
+			break
+		}
+
+		id := candidates[best].ID
+		given[call.Floor][call.Dir] = statesync.HallCallPairState{State: statesync.HSProcessing, By: id}
+		result[id][call.Floor][call.Dir] = true
+	}
+
+	return result
+}
+
+// activeHallCalls lists the calls that need an elevator, bottom floor first
+// and up before down
+func activeHallCalls(hallCalls [][2]statesync.HallCallPairState) []HallCall {
+	var calls []HallCall
+	for floor := range hallCalls {
+		for dir, call := range hallCalls[floor] {
+			if call.State != statesync.HSNone {
+				calls = append(calls, HallCall{Floor: floor, Dir: statesync.HallCallDir(dir)})
+			}
+		}
+	}
+	return calls
+}
+
+// sortedCandidates returns the elevators that may take hall calls, by ID
+func sortedCandidates(states []statesync.RemoteElevatorState) []statesync.RemoteElevatorState {
+	var candidates []statesync.RemoteElevatorState
+	for _, s := range states {
+		if s.AcceptsHallCalls() {
+			candidates = append(candidates, s)
+		}
+	}
+
+	slices.SortFunc(candidates, func(a, b statesync.RemoteElevatorState) int {
+		return a.ID - b.ID
+	})
+
+	return candidates
+}
+
+func emptyAssignment(numFloors int, states []statesync.RemoteElevatorState) map[int][][2]bool {
+	result := make(map[int][][2]bool, len(states))
+	for _, s := range states {
+		result[s.ID] = make([][2]bool, numFloors)
+	}
+	return result
+}
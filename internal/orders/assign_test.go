@@ -0,0 +1,203 @@
+package orders
+
+import (
+	"math/rand"
+	"testing"
+
+	statesync "github.com/Mosazghi/elevator-ttk4145/internal/sync"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func hallCallsWith(calls ...HallCall) [][2]statesync.HallCallPairState {
+	hallCalls := make([][2]statesync.HallCallPairState, 4)
+	for _, c := range calls {
+		hallCalls[c.Floor][c.Dir] = statesync.HallCallPairState{State: statesync.HSAvailable}
+	}
+	return hallCalls
+}
+
+func TestTimeToIdleAssigner_NearestIdleCarWins(t *testing.T) {
+	a := &TimeToIdleAssigner{Timing: testTiming}
+	states := []statesync.RemoteElevatorState{*idleAt(1, 0), *idleAt(2, 3)}
+
+	result := a.Assign(hallCallsWith(HallCall{Floor: 3, Dir: statesync.HDDown}), states)
+
+	assert.True(t, result[2][3][statesync.HDDown])
+	assert.False(t, result[1][3][statesync.HDDown])
+}
+
+func TestTimeToIdleAssigner_TieGoesToLowestID(t *testing.T) {
+	a := &TimeToIdleAssigner{Timing: testTiming}
+	states := []statesync.RemoteElevatorState{*idleAt(7, 1), *idleAt(3, 1), *idleAt(5, 1)}
+
+	result := a.Assign(hallCallsWith(HallCall{Floor: 2, Dir: statesync.HDUp}), states)
+
+	assert.True(t, result[3][2][statesync.HDUp], "equal cost should go to the lowest ID")
+	assert.False(t, result[5][2][statesync.HDUp])
+	assert.False(t, result[7][2][statesync.HDUp])
+}
+
+func TestTimeToIdleAssigner_SkipsCarsNotAcceptingHallCalls(t *testing.T) {
+	a := &TimeToIdleAssigner{Timing: testTiming}
+	independent := idleAt(1, 2)
+	independent.Independent = true
+	full := idleAt(2, 2)
+	full.Full = true
+	states := []statesync.RemoteElevatorState{*independent, *full, *idleAt(3, 0)}
+
+	result := a.Assign(hallCallsWith(HallCall{Floor: 2, Dir: statesync.HDUp}), states)
+
+	require.Contains(t, result, 1, "every elevator should get a matrix")
+	assert.False(t, result[1][2][statesync.HDUp])
+	assert.False(t, result[2][2][statesync.HDUp])
+	assert.True(t, result[3][2][statesync.HDUp])
+}
+
+func TestTimeToIdleAssigner_Deterministic(t *testing.T) {
+	a := &TimeToIdleAssigner{Timing: testTiming}
+	hallCalls := hallCallsWith(
+		HallCall{Floor: 0, Dir: statesync.HDUp},
+		HallCall{Floor: 1, Dir: statesync.HDDown},
+		HallCall{Floor: 2, Dir: statesync.HDUp},
+		HallCall{Floor: 3, Dir: statesync.HDDown},
+	)
+	hallCalls[1][statesync.HDDown] = statesync.HallCallPairState{State: statesync.HSProcessing, By: 2}
+	states := []statesync.RemoteElevatorState{*idleAt(1, 0), *idleAt(2, 1), *idleAt(3, 3), *idleAt(4, 2)}
+
+	expected := a.Assign(hallCalls, states)
+
+	rng := rand.New(rand.NewSource(1))
+	for range 20 {
+		shuffled := append([]statesync.RemoteElevatorState(nil), states...)
+		rng.Shuffle(len(shuffled), func(i, j int) { shuffled[i], shuffled[j] = shuffled[j], shuffled[i] })
+
+		assert.Equal(t, expected, a.Assign(hallCalls, shuffled), "order of states should not matter")
+	}
+
+	// Every active call goes to exactly one elevator
+	for floor := range hallCalls {
+		for dir := range hallCalls[floor] {
+			if hallCalls[floor][dir].State == statesync.HSNone {
+				continue
+			}
+			count := 0
+			for _, m := range expected {
+				if m[floor][dir] {
+					count++
+				}
+			}
+			assert.Equal(t, 1, count, "floor %d dir %d", floor, dir)
+		}
+	}
+}
+
+func TestNewAssigner(t *testing.T) {
+	for _, name := range Strategies {
+		a, err := NewAssigner(name, testTiming)
+		require.NoError(t, err, name)
+		require.NotNil(t, a, name)
+	}
+
+	_, err := NewAssigner("elevator-roulette", testTiming)
+	assert.Error(t, err)
+}
+
+// Every strategy hands each active call to exactly one accepting elevator,
+// whatever the order of the states
+func TestAssigners_AssignEveryCallOnce(t *testing.T) {
+	hallCalls := hallCallsWith(
+		HallCall{Floor: 0, Dir: statesync.HDUp},
+		HallCall{Floor: 1, Dir: statesync.HDUp},
+		HallCall{Floor: 2, Dir: statesync.HDDown},
+		HallCall{Floor: 3, Dir: statesync.HDDown},
+	)
+	independent := idleAt(4, 1)
+	independent.Independent = true
+	states := []statesync.RemoteElevatorState{*idleAt(3, 3), *idleAt(1, 0), *independent, *idleAt(2, 2)}
+	reversed := []statesync.RemoteElevatorState{states[3], states[2], states[1], states[0]}
+
+	for _, name := range Strategies {
+		t.Run(name, func(t *testing.T) {
+			a, err := NewAssigner(name, testTiming)
+			require.NoError(t, err)
+
+			result := a.Assign(hallCalls, states)
+			assert.Equal(t, result, a.Assign(hallCalls, reversed))
+
+			for _, call := range activeHallCalls(hallCalls) {
+				count := 0
+				for _, m := range result {
+					if m[call.Floor][call.Dir] {
+						count++
+					}
+				}
+				assert.Equal(t, 1, count, "%+v", call)
+				assert.False(t, result[4][call.Floor][call.Dir], "independent car should get nothing")
+			}
+		})
+	}
+}
+
+func TestNearestCarAssigner(t *testing.T) {
+	a := &NearestCarAssigner{}
+	states := []statesync.RemoteElevatorState{*idleAt(1, 0), *idleAt(2, 3)}
+
+	result := a.Assign(hallCallsWith(HallCall{Floor: 1, Dir: statesync.HDUp}, HallCall{Floor: 2, Dir: statesync.HDUp}), states)
+
+	assert.True(t, result[1][1][statesync.HDUp])
+	assert.True(t, result[2][2][statesync.HDUp])
+}
+
+func TestRoundRobinAssigner_TakesTurns(t *testing.T) {
+	a := &RoundRobinAssigner{}
+	states := []statesync.RemoteElevatorState{*idleAt(1, 0), *idleAt(2, 0)}
+	hallCalls := make([][2]statesync.HallCallPairState, 4)
+	hallCalls[3][statesync.HDDown] = statesync.HallCallPairState{State: statesync.HSAvailable, Clock: 1}
+	hallCalls[1][statesync.HDUp] = statesync.HallCallPairState{State: statesync.HSAvailable, Clock: 2}
+	hallCalls[2][statesync.HDUp] = statesync.HallCallPairState{State: statesync.HSAvailable, Clock: 3}
+
+	result := a.Assign(hallCalls, states)
+
+	// Dealt in the order the calls were made, not by floor
+	assert.True(t, result[1][3][statesync.HDDown])
+	assert.True(t, result[2][1][statesync.HDUp])
+	assert.True(t, result[1][2][statesync.HDUp])
+}
+
+func TestRoundRobinAssigner_TurnPassesOn(t *testing.T) {
+	a := &RoundRobinAssigner{}
+	states := []statesync.RemoteElevatorState{*idleAt(1, 0), *idleAt(2, 0), *idleAt(3, 0)}
+	hallCalls := make([][2]statesync.HallCallPairState, 4)
+	hallCalls[0][statesync.HDUp] = statesync.HallCallPairState{State: statesync.HSProcessing, By: 3, Clock: 4}
+	hallCalls[3][statesync.HDDown] = statesync.HallCallPairState{State: statesync.HSProcessing, By: 1, Clock: 7}
+	hallCalls[2][statesync.HDUp] = statesync.HallCallPairState{State: statesync.HSAvailable, Clock: 8}
+
+	result := a.Assign(hallCalls, states)
+
+	assert.True(t, result[3][0][statesync.HDUp], "a call should stay with its car")
+	assert.True(t, result[1][3][statesync.HDDown], "a call should stay with its car")
+	assert.True(t, result[2][2][statesync.HDUp], "car 1 took the last call, so it is car 2's turn")
+
+	// Once car 2 has taken it, the next call is car 3's
+	hallCalls[2][statesync.HDUp] = statesync.HallCallPairState{State: statesync.HSProcessing, By: 2, Clock: 9}
+	hallCalls[1][statesync.HDDown] = statesync.HallCallPairState{State: statesync.HSAvailable, Clock: 10}
+
+	result = a.Assign(hallCalls, states)
+
+	assert.True(t, result[2][2][statesync.HDUp])
+	assert.True(t, result[3][1][statesync.HDDown])
+}
+
+func TestMinMaxWaitAssigner_SpreadsLoad(t *testing.T) {
+	a := &MinMaxWaitAssigner{Timing: testTiming}
+	// Both cars at the bottom, so stacking both calls on car 1 would make
+	// the call at floor 3 wait for the stop at floor 2
+	states := []statesync.RemoteElevatorState{*idleAt(1, 0), *idleAt(2, 0)}
+	hallCalls := hallCallsWith(HallCall{Floor: 2, Dir: statesync.HDUp}, HallCall{Floor: 3, Dir: statesync.HDDown})
+
+	result := a.Assign(hallCalls, states)
+
+	assert.True(t, result[1][2][statesync.HDUp])
+	assert.True(t, result[2][3][statesync.HDDown], "second car should take the far call to cut the longest wait")
+}
@@ -0,0 +1,216 @@
+package orders
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"time"
+
+	statesync "github.com/Mosazghi/elevator-ttk4145/internal/sync"
+)
+
+// Assigner decides which elevator serves which hall call. Implementations must
+// be deterministic, so that every node computes the same assignment from the
+// same worldview without exchanging it.
+type Assigner interface {
+	// Assign returns the hall calls each elevator should serve, keyed by
+	// elevator ID. Every given elevator gets a matrix, possibly empty.
+	Assign(hallCalls [][2]statesync.HallCallPairState, states []statesync.RemoteElevatorState) map[int][][2]bool
+}
+
+// Names of the built-in assignment strategies
+const (
+	StrategyNearestCar = "nearest-car"
+	StrategyTimeToIdle = "time-to-idle"
+	StrategyRoundRobin = "round-robin"
+	StrategyMinMaxWait = "min-max-wait"
+	StrategyHRA        = "hra"      // Go port of hall_request_assigner
+	StrategyHRAExec    = "hra-exec" // the hall_request_assigner executable
+)
+
+// Strategies lists the names NewAssigner accepts
+var Strategies = []string{
+	StrategyNearestCar,
+	StrategyTimeToIdle,
+	StrategyRoundRobin,
+	StrategyMinMaxWait,
+	StrategyHRA,
+	StrategyHRAExec,
+}
+
+// NewAssigner creates the assigner for the named strategy
+func NewAssigner(strategy string, t Timing) (Assigner, error) {
+	switch strategy {
+	case StrategyNearestCar:
+		return &NearestCarAssigner{}, nil
+	case StrategyTimeToIdle:
+		return &TimeToIdleAssigner{Timing: t}, nil
+	case StrategyRoundRobin:
+		return &RoundRobinAssigner{}, nil
+	case StrategyMinMaxWait:
+		return &MinMaxWaitAssigner{Timing: t}, nil
+	case StrategyHRA:
+		return &HRAAssigner{Timing: t}, nil
+	case StrategyHRAExec:
+		return &ExecAssigner{Path: DefaultHRAExecutable, Timing: t}, nil
+	}
+	return nil, fmt.Errorf("unknown assignment strategy %q", strategy)
+}
+
+// NearestCarAssigner gives each hall call to the elevator closest to its
+// floor, regardless of where that elevator is heading
+type NearestCarAssigner struct{}
+
+func (a *NearestCarAssigner) Assign(hallCalls [][2]statesync.HallCallPairState, states []statesync.RemoteElevatorState) map[int][][2]bool {
+	candidates := sortedCandidates(states)
+	result := emptyAssignment(len(hallCalls), states)
+
+	for _, call := range activeHallCalls(hallCalls) {
+		best := -1
+		bestDist := 0
+		for i, s := range candidates {
+			dist := abs(max(s.CurrentFloor, 0) - call.Floor)
+			if best == -1 || dist < bestDist {
+				best, bestDist = i, dist
+			}
+		}
+
+		if best == -1 {
+			break
+		}
+		result[candidates[best].ID][call.Floor][call.Dir] = true
+	}
+
+	return result
+}
+
+// RoundRobinAssigner deals the hall calls out in turn, in the order they were
+// made. A call stays with the elevator processing it, and the turn passes on
+// from whichever elevator took a call last, so the turn is read from the
+// worldview and every node agrees on it.
+type RoundRobinAssigner struct{}
+
+func (a *RoundRobinAssigner) Assign(hallCalls [][2]statesync.HallCallPairState, states []statesync.RemoteElevatorState) map[int][][2]bool {
+	candidates := sortedCandidates(states)
+	result := emptyAssignment(len(hallCalls), states)
+
+	if len(candidates) == 0 {
+		return result
+	}
+
+	turn := 0
+	var lastTaken uint64
+	var waiting []HallCall
+
+	for _, call := range activeHallCalls(hallCalls) {
+		current := hallCalls[call.Floor][call.Dir]
+		holder := slices.IndexFunc(candidates, func(s statesync.RemoteElevatorState) bool {
+			return s.ID == current.By
+		})
+
+		if current.State != statesync.HSProcessing || holder == -1 {
+			waiting = append(waiting, call)
+			continue
+		}
+
+		result[current.By][call.Floor][call.Dir] = true
+		if current.Clock >= lastTaken {
+			lastTaken = current.Clock
+			turn = (holder + 1) % len(candidates)
+		}
+	}
+
+	// Oldest call first, the floor and direction break ties
+	slices.SortStableFunc(waiting, func(x, y HallCall) int {
+		return cmp.Compare(hallCalls[x.Floor][x.Dir].Clock, hallCalls[y.Floor][y.Dir].Clock)
+	})
+
+	for _, call := range waiting {
+		result[candidates[turn].ID][call.Floor][call.Dir] = true
+		turn = (turn + 1) % len(candidates)
+	}
+
+	return result
+}
+
+// MinMaxWaitAssigner gives each hall call to the elevator that keeps the
+// longest wait of any call given out so far as short as possible. Ties go to
+// the shortest time to idle and then to the lowest ID.
+type MinMaxWaitAssigner struct {
+	Timing Timing
+}
+
+func (a *MinMaxWaitAssigner) Assign(hallCalls [][2]statesync.HallCallPairState, states []statesync.RemoteElevatorState) map[int][][2]bool {
+	candidates := sortedCandidates(states)
+	result := emptyAssignment(len(hallCalls), states)
+
+	// Longest wait among the calls given to each candidate
+	worst := make([]time.Duration, len(candidates))
+
+	for _, call := range activeHallCalls(hallCalls) {
+		best := -1
+		var bestMax, bestWorst, bestIdle time.Duration
+
+		for i := range candidates {
+			trial := append([][2]bool(nil), result[candidates[i].ID]...)
+			trial[call.Floor][call.Dir] = true
+
+			waits, idle := arrivalTimes(&candidates[i], trial, a.Timing)
+			ownWorst := maxWait(waits)
+
+			overall := ownWorst
+			for j := range candidates {
+				if j != i {
+					overall = max(overall, worst[j])
+				}
+			}
+
+			better := best == -1 || overall < bestMax ||
+				(overall == bestMax && (ownWorst < bestWorst ||
+					(ownWorst == bestWorst && idle < bestIdle)))
+			if better {
+				best, bestMax, bestWorst, bestIdle = i, overall, ownWorst, idle
+			}
+		}
+
+		if best == -1 {
+			break
+		}
+
+		result[candidates[best].ID][call.Floor][call.Dir] = true
+		worst[best] = bestWorst
+	}
+
+	return result
+}
+
+// arrivalTimes simulates the elevator serving its cab calls and the given hall
+// calls. It returns when the door opens for each hall call, and the time to idle.
+func arrivalTimes(s *statesync.RemoteElevatorState, hallCalls [][2]bool, t Timing) (map[HallCall]time.Duration, time.Duration) {
+	waits := make(map[HallCall]time.Duration)
+
+	idle := newSimElevator(s, hallCalls).timeToIdle(t.For(s), func(floor int, cleared [3]bool, at time.Duration) {
+		for dir := range 2 {
+			if cleared[dir] {
+				waits[HallCall{Floor: floor, Dir: statesync.HallCallDir(dir)}] = at
+			}
+		}
+	})
+
+	return waits, idle
+}
+
+func maxWait(waits map[HallCall]time.Duration) time.Duration {
+	var worst time.Duration
+	for _, w := range waits {
+		worst = max(worst, w)
+	}
+	return worst
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
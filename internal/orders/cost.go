@@ -1,66 +1,232 @@
+// Package orders decides which elevator answers which hall call
 package orders
 
-// import (
-// 	. "Heisern/pkg/elevator"
-// )
-
-// func FindBestCost(e *ElevState) (Order, bool) {
-// 	currDir := e.Dir
-// 	target := e.Target
-// 	cF := e.CurrFloor
-// 	orders := e.Orders
-// 	// var bestOrder Order
-//
-// 	for f := range orders {
-// 		for b := range orders[f] {
-// 			order := orders[f][b]
-// 			if order {
-// 				// if found order's floor is below ours AND we are moving up, then skip
-// 				if cF > f && currDir == elevio.MD_Up {
-// 					continue
-// 				}
-// 				// If order found on our floor then skip
-// 				if cF == f {
-// 					continue
-// 				}
-// 				// If order's floor is larger than targets floor then skip
-// 				if f > target.Floor && currDir == elevio.MD_Up {
-// 					continue
-// 				}
-//
-// 				// Same for down
-// 				if f < target.Floor && currDir == elevio.MD_Down {
-// 					continue
-// 				}
-//
-// 				switch elevio.ButtonType(b) {
-// 				case elevio.BT_HallUp:
-// 					if currDir == elevio.MD_Up {
-// 						return Order{
-// 							Floor: f,
-// 							RType: elevio.BT_HallUp,
-// 						}, true
-// 					}
-//
-// 				case elevio.BT_HallDown:
-//
-// 					if currDir == elevio.MD_Down {
-// 						return Order{
-// 							f,
-// 							elevio.BT_HallDown,
-// 						}, true
-// 					}
-// 				case elevio.BT_Cab:
-//
-// 				}
-// 				// If current floor and there is an order that is right above us and is
-// 				// different from currentTarget
-// 			}
-// 		}
-// 	}
-//
-// 	return Order{
-// 		-1,
-// 		elevio.BT_HallUp,
-// 	}, true
-// }
+import (
+	"time"
+
+	"github.com/Mosazghi/elevator-ttk4145/internal/config"
+	"github.com/Mosazghi/elevator-ttk4145/internal/elevator"
+	elevio "github.com/Mosazghi/elevator-ttk4145/internal/hw"
+	statesync "github.com/Mosazghi/elevator-ttk4145/internal/sync"
+)
+
+// Timing holds the durations the cost function simulates with
+type Timing struct {
+	Travel   time.Duration // between two adjacent floors
+	DoorOpen time.Duration
+	// Segments, if set, overrides Travel for each segment, segment i being
+	// between floor i and i+1
+	Segments []time.Duration
+}
+
+// For returns the timing to simulate the elevator with: its own learned
+// times where it has shared them, t otherwise
+func (t Timing) For(s *statesync.RemoteElevatorState) Timing {
+	if len(s.SegmentTimes) > 0 {
+		t.Segments = s.SegmentTimes
+	}
+	if s.DoorTime > 0 {
+		t.DoorOpen = s.DoorTime
+	}
+	return t
+}
+
+// travel is the time to go one floor from floor in the given direction
+func (t Timing) travel(floor int, dir elevio.MotorDirection) time.Duration {
+	segment := floor
+	if dir == elevio.Down {
+		segment--
+	}
+
+	if segment >= 0 && segment < len(t.Segments) && t.Segments[segment] > 0 {
+		return t.Segments[segment]
+	}
+	return t.Travel
+}
+
+// HallCall identifies a hall call by floor and direction
+type HallCall struct {
+	Floor int
+	Dir   statesync.HallCallDir
+}
+
+// TimingFromConfig takes the simulation durations from the node configuration
+func TimingFromConfig(cfg *config.Config) Timing {
+	return Timing{
+		Travel:   cfg.TravelTime(),
+		DoorOpen: cfg.DoorOpenDuration(),
+	}
+}
+
+// TimeToIdle estimates how long the elevator needs to serve its cab calls, the
+// hall calls it is processing and the candidate call, and become idle
+func TimeToIdle(s *statesync.RemoteElevatorState, hallCalls [][2]statesync.HallCallPairState, call HallCall, t Timing) time.Duration {
+	assigned := make([][2]bool, len(hallCalls))
+	for floor := range hallCalls {
+		for dir, c := range hallCalls[floor] {
+			assigned[floor][dir] = c.State == statesync.HSProcessing && c.By == s.ID
+		}
+	}
+
+	if call.Floor >= 0 && call.Floor < len(assigned) {
+		assigned[call.Floor][call.Dir] = true
+	}
+
+	return newSimElevator(s, assigned).timeToIdle(t.For(s), nil)
+}
+
+// simElevator is the part of an elevator's state the cost function simulates
+type simElevator struct {
+	floor     int
+	dir       elevio.MotorDirection
+	behavior  elevator.Behavior
+	requests  [][3]bool
+	numFloors int
+	// waiting holds the destinations of the passengers waiting at each
+	// floor. They become cab requests when the car stops there.
+	waiting [][]int
+}
+
+func newSimElevator(s *statesync.RemoteElevatorState, hallCalls [][2]bool) *simElevator {
+	e := &simElevator{
+		floor:     s.CurrentFloor,
+		dir:       s.Direction,
+		behavior:  s.Behavior,
+		requests:  make([][3]bool, len(hallCalls)),
+		numFloors: len(hallCalls),
+	}
+
+	for floor := range e.requests {
+		e.requests[floor][elevio.HallUp] = hallCalls[floor][statesync.HDUp]
+		e.requests[floor][elevio.HallDown] = hallCalls[floor][statesync.HDDown]
+		if floor < len(s.CabCalls) {
+			e.requests[floor][elevio.Cab] = s.CabCalls[floor]
+		}
+	}
+
+	// Between floors at start-up, the best guess is the bottom floor
+	e.floor = min(max(e.floor, 0), e.numFloors-1)
+
+	return e
+}
+
+// stopFunc is told which requests an elevator clears at a floor, and how
+// long after the start of the simulation the door opens there
+type stopFunc func(floor int, cleared [3]bool, at time.Duration)
+
+// timeToIdle runs the elevator until it has no requests left. onStop may be nil.
+func (e *simElevator) timeToIdle(t Timing, onStop stopFunc) time.Duration {
+	var duration time.Duration
+
+	switch e.behavior {
+	case elevator.BIdle:
+		e.dir = e.chooseDirection()
+		if e.dir == elevio.Stop {
+			// Requests at this floor are served right away
+			e.clearAtCurrentFloor(duration, onStop)
+			e.dir = e.chooseDirection()
+			if e.dir == elevio.Stop {
+				return duration
+			}
+			// Passengers boarded and keyed in where they are going
+			duration += t.DoorOpen
+		}
+	case elevator.BMoving:
+		duration += t.travel(e.floor, e.dir) / 2
+		e.step()
+	case elevator.BDoorOpen:
+		duration -= t.DoorOpen / 2
+	}
+
+	// Every floor is visited at most twice before the car turns for good
+	for range 4 * e.numFloors {
+		if e.shouldStop() {
+			e.clearAtCurrentFloor(max(duration, 0), onStop)
+			duration += t.DoorOpen
+			e.dir = e.chooseDirection()
+			if e.dir == elevio.Stop {
+				return duration
+			}
+		}
+		duration += t.travel(e.floor, e.dir)
+		e.step()
+	}
+
+	return duration
+}
+
+func (e *simElevator) step() {
+	e.floor = min(max(e.floor+int(e.dir), 0), e.numFloors-1)
+}
+
+func (e *simElevator) hasRequestsAbove() bool {
+	for floor := e.floor + 1; floor < e.numFloors; floor++ {
+		if e.requests[floor] != [3]bool{} {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *simElevator) hasRequestsBelow() bool {
+	for floor := 0; floor < e.floor; floor++ {
+		if e.requests[floor] != [3]bool{} {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *simElevator) shouldStop() bool {
+	r := e.requests[e.floor]
+	switch e.dir {
+	case elevio.Down:
+		return r[elevio.HallDown] || r[elevio.Cab] || !e.hasRequestsBelow()
+	case elevio.Up:
+		return r[elevio.HallUp] || r[elevio.Cab] || !e.hasRequestsAbove()
+	}
+	return true
+}
+
+func (e *simElevator) chooseDirection() elevio.MotorDirection {
+	switch e.dir {
+	case elevio.Up:
+		if e.hasRequestsAbove() {
+			return elevio.Up
+		}
+		if e.hasRequestsBelow() {
+			return elevio.Down
+		}
+	case elevio.Down:
+		if e.hasRequestsBelow() {
+			return elevio.Down
+		}
+		if e.hasRequestsAbove() {
+			return elevio.Up
+		}
+	default:
+		// From a stop the state machine looks up first
+		if e.hasRequestsAbove() {
+			return elevio.Up
+		}
+		if e.hasRequestsBelow() {
+			return elevio.Down
+		}
+	}
+	return elevio.Stop
+}
+
+func (e *simElevator) clearAtCurrentFloor(at time.Duration, onStop stopFunc) {
+	if onStop != nil && e.requests[e.floor] != [3]bool{} {
+		onStop(e.floor, e.requests[e.floor], at)
+	}
+	e.requests[e.floor] = [3]bool{}
+
+	if e.waiting == nil {
+		return
+	}
+	for _, to := range e.waiting[e.floor] {
+		e.requests[to][elevio.Cab] = true
+	}
+	e.waiting[e.floor] = nil
+}
@@ -0,0 +1,80 @@
+package orders
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Mosazghi/elevator-ttk4145/internal/elevator"
+	elevio "github.com/Mosazghi/elevator-ttk4145/internal/hw"
+	statesync "github.com/Mosazghi/elevator-ttk4145/internal/sync"
+	"github.com/stretchr/testify/assert"
+)
+
+var testTiming = Timing{Travel: 2 * time.Second, DoorOpen: 3 * time.Second}
+
+func idleAt(id, floor int) *statesync.RemoteElevatorState {
+	s := statesync.NewRemoteElevatorState(id, 4)
+	s.CurrentFloor = floor
+	return s
+}
+
+func TestTimeToIdle_IdleAtCallFloor(t *testing.T) {
+	s := idleAt(1, 2)
+	hallCalls := make([][2]statesync.HallCallPairState, 4)
+
+	cost := TimeToIdle(s, hallCalls, HallCall{Floor: 2, Dir: statesync.HDUp}, testTiming)
+
+	assert.Equal(t, time.Duration(0), cost, "call at an idle car's floor is served at once")
+}
+
+func TestTimeToIdle_IdleTravel(t *testing.T) {
+	s := idleAt(1, 0)
+	hallCalls := make([][2]statesync.HallCallPairState, 4)
+
+	cost := TimeToIdle(s, hallCalls, HallCall{Floor: 3, Dir: statesync.HDDown}, testTiming)
+
+	assert.Equal(t, 3*testTiming.Travel+testTiming.DoorOpen, cost)
+}
+
+func TestTimeToIdle_CountsOwnProcessingCalls(t *testing.T) {
+	s := idleAt(1, 0)
+	hallCalls := make([][2]statesync.HallCallPairState, 4)
+	hallCalls[3][statesync.HDDown] = statesync.HallCallPairState{State: statesync.HSProcessing, By: 1}
+	hallCalls[2][statesync.HDDown] = statesync.HallCallPairState{State: statesync.HSProcessing, By: 2}
+
+	cost := TimeToIdle(s, hallCalls, HallCall{Floor: 1, Dir: statesync.HDUp}, testTiming)
+
+	// Stops at 1 on the way up to its own call at 3, ignores the call held by 2
+	assert.Equal(t, 3*testTiming.Travel+2*testTiming.DoorOpen, cost)
+}
+
+func TestTimeToIdle_MovingAndDoorOpen(t *testing.T) {
+	hallCalls := make([][2]statesync.HallCallPairState, 4)
+
+	moving := idleAt(1, 1)
+	moving.Behavior = elevator.BMoving
+	moving.Direction = elevio.Up
+	moving.TargetFloor = 2
+
+	cost := TimeToIdle(moving, hallCalls, HallCall{Floor: 2, Dir: statesync.HDUp}, testTiming)
+	assert.Equal(t, testTiming.Travel/2+testTiming.DoorOpen, cost)
+
+	doorOpen := idleAt(2, 1)
+	doorOpen.Behavior = elevator.BDoorOpen
+	doorOpen.DoorState = elevator.DSOpen
+	doorOpen.CabCalls[1] = true
+
+	cost = TimeToIdle(doorOpen, hallCalls, HallCall{Floor: 0, Dir: statesync.HDUp}, testTiming)
+	assert.Equal(t, -testTiming.DoorOpen/2+testTiming.DoorOpen+testTiming.Travel+testTiming.DoorOpen, cost)
+}
+
+func TestTimeToIdle_IdleGoesUpFirst(t *testing.T) {
+	s := idleAt(1, 1)
+	hallCalls := make([][2]statesync.HallCallPairState, 4)
+	hallCalls[0][statesync.HDUp] = statesync.HallCallPairState{State: statesync.HSProcessing, By: 1}
+
+	cost := TimeToIdle(s, hallCalls, HallCall{Floor: 3, Dir: statesync.HDDown}, testTiming)
+
+	// Up to 3 first, then back down to 0, as the state machine would
+	assert.Equal(t, 5*testTiming.Travel+2*testTiming.DoorOpen, cost)
+}
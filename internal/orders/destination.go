@@ -0,0 +1,128 @@
+package orders
+
+import (
+	"time"
+
+	elevio "github.com/Mosazghi/elevator-ttk4145/internal/hw"
+	statesync "github.com/Mosazghi/elevator-ttk4145/internal/sync"
+)
+
+// DestCall identifies a destination call by origin and destination floor
+type DestCall struct {
+	From int
+	To   int
+}
+
+// Dir is the hall direction the passenger travels in
+func (c DestCall) Dir() statesync.HallCallDir {
+	if c.To > c.From {
+		return statesync.HDUp
+	}
+	return statesync.HDDown
+}
+
+// Pickup is the hall call the car answers to pick the passenger up
+func (c DestCall) Pickup() HallCall {
+	return HallCall{Floor: c.From, Dir: c.Dir()}
+}
+
+// DestinationAssigner gives each destination call to the elevator whose time
+// to idle grows the least by taking the passenger. A passenger going where a
+// car already stops adds no stops to it, so passengers with similar
+// destinations end up in the same car. Ties go to the lowest elevator ID.
+type DestinationAssigner struct {
+	Timing Timing
+}
+
+// Assign returns the destination calls each elevator should take, by origin
+// and then destination floor. Hall calls processed by an elevator count as
+// part of its load.
+func (a *DestinationAssigner) Assign(hallCalls [][2]statesync.HallCallPairState, destCalls [][]statesync.HallCallPairState, states []statesync.RemoteElevatorState) map[int][][]bool {
+	candidates := sortedCandidates(states)
+	result := make(map[int][][]bool, len(states))
+	for _, s := range states {
+		result[s.ID] = make([][]bool, len(destCalls))
+		for from := range destCalls {
+			result[s.ID][from] = make([]bool, len(destCalls[from]))
+		}
+	}
+
+	// The calls given out so far, in the form DestinationTimeToIdle counts as
+	// an elevator's existing load
+	given := make([][]statesync.HallCallPairState, len(destCalls))
+	for from := range destCalls {
+		given[from] = make([]statesync.HallCallPairState, len(destCalls[from]))
+	}
+
+	for _, call := range activeDestCalls(destCalls) {
+		best := -1
+		var bestCost time.Duration
+		for i := range candidates {
+			before := DestinationTimeToIdle(&candidates[i], hallCalls, given, nil, a.Timing)
+			after := DestinationTimeToIdle(&candidates[i], hallCalls, given, &call, a.Timing)
+			if cost := after - before; best == -1 || cost < bestCost {
+				best, bestCost = i, cost
+			}
+		}
+
+		if best == -1 {
+			break
+		}
+
+		id := candidates[best].ID
+		given[call.From][call.To] = statesync.HallCallPairState{State: statesync.HSProcessing, By: id}
+		result[id][call.From][call.To] = true
+	}
+
+	return result
+}
+
+// DestinationTimeToIdle estimates how long the elevator needs to serve its cab
+// calls, the hall and destination calls it is processing and the candidate
+// call, if any, and become idle. A destination call is a stop at its origin,
+// and a stop at its destination once the passenger is aboard.
+func DestinationTimeToIdle(s *statesync.RemoteElevatorState, hallCalls [][2]statesync.HallCallPairState, destCalls [][]statesync.HallCallPairState, call *DestCall, t Timing) time.Duration {
+	assigned := make([][2]bool, len(hallCalls))
+	for floor := range hallCalls {
+		for dir, c := range hallCalls[floor] {
+			assigned[floor][dir] = c.State == statesync.HSProcessing && c.By == s.ID
+		}
+	}
+
+	e := newSimElevator(s, assigned)
+	e.waiting = make([][]int, e.numFloors)
+
+	board := func(c DestCall) {
+		if c.From < 0 || c.From >= e.numFloors || c.To < 0 || c.To >= e.numFloors {
+			return
+		}
+		e.requests[c.From][elevio.ButtonType(c.Dir())] = true
+		e.waiting[c.From] = append(e.waiting[c.From], c.To)
+	}
+
+	for _, c := range activeDestCalls(destCalls) {
+		if d := destCalls[c.From][c.To]; d.State == statesync.HSProcessing && d.By == s.ID {
+			board(c)
+		}
+	}
+
+	if call != nil {
+		board(*call)
+	}
+
+	return e.timeToIdle(t.For(s), nil)
+}
+
+// activeDestCalls lists the destination calls that need an elevator, by
+// origin and then destination floor
+func activeDestCalls(destCalls [][]statesync.HallCallPairState) []DestCall {
+	var calls []DestCall
+	for from := range destCalls {
+		for to, call := range destCalls[from] {
+			if call.State != statesync.HSNone && from != to {
+				calls = append(calls, DestCall{From: from, To: to})
+			}
+		}
+	}
+	return calls
+}
@@ -0,0 +1,49 @@
+package orders
+
+import (
+	"testing"
+
+	statesync "github.com/Mosazghi/elevator-ttk4145/internal/sync"
+	"github.com/stretchr/testify/assert"
+)
+
+func destCallsWith(calls ...DestCall) [][]statesync.HallCallPairState {
+	destCalls := make([][]statesync.HallCallPairState, 4)
+	for from := range destCalls {
+		destCalls[from] = make([]statesync.HallCallPairState, 4)
+	}
+	for _, c := range calls {
+		destCalls[c.From][c.To] = statesync.HallCallPairState{State: statesync.HSAvailable}
+	}
+	return destCalls
+}
+
+func TestDestinationTimeToIdle_PickupThenDestination(t *testing.T) {
+	s := idleAt(1, 1)
+	destCalls := destCallsWith()
+
+	cost := DestinationTimeToIdle(s, make([][2]statesync.HallCallPairState, 4), destCalls, &DestCall{From: 0, To: 3}, testTiming)
+
+	assert.Equal(t, testTiming.Travel+testTiming.DoorOpen+3*testTiming.Travel+testTiming.DoorOpen, cost)
+}
+
+func TestDestinationAssigner_GroupsSameDestination(t *testing.T) {
+	a := &DestinationAssigner{Timing: testTiming}
+	hallCalls := make([][2]statesync.HallCallPairState, 4)
+	states := []statesync.RemoteElevatorState{*idleAt(1, 0), *idleAt(2, 1)}
+
+	alone := a.Assign(hallCalls, destCallsWith(DestCall{From: 1, To: 3}), states)
+
+	assert.True(t, alone[2][1][3], "on its own the passenger goes to the car at their floor")
+
+	result := a.Assign(hallCalls, destCallsWith(DestCall{From: 0, To: 3}, DestCall{From: 1, To: 3}), states)
+
+	assert.True(t, result[1][0][3])
+	assert.True(t, result[1][1][3], "passenger going the same way should join the car already going there")
+	assert.False(t, result[2][1][3])
+}
+
+func TestDestCall_Dir(t *testing.T) {
+	assert.Equal(t, HallCall{Floor: 1, Dir: statesync.HDUp}, DestCall{From: 1, To: 2}.Pickup())
+	assert.Equal(t, HallCall{Floor: 3, Dir: statesync.HDDown}, DestCall{From: 3, To: 0}.Pickup())
+}
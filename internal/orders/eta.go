@@ -0,0 +1,81 @@
+package orders
+
+import (
+	"time"
+
+	statesync "github.com/Mosazghi/elevator-ttk4145/internal/sync"
+)
+
+// NoCar is the ETA car of a hall call no elevator can take
+const NoCar = -1
+
+// ETA is when a hall call is expected to be answered
+type ETA struct {
+	Call HallCall
+	Car  int           // ID of the assigned elevator, or NoCar
+	In   time.Duration // until the car's door opens at the call's floor
+}
+
+// EstimateArrivals returns an ETA for every active hall call, bottom floor
+// first and up before down. The assignment is what an Assigner returned for
+// the same hall calls and elevators; each car is simulated serving its cab
+// calls and the hall calls assigned to it.
+func EstimateArrivals(assignment map[int][][2]bool, hallCalls [][2]statesync.HallCallPairState, states []statesync.RemoteElevatorState, t Timing) []ETA {
+	arrivals := make(map[HallCall]ETA)
+	for i := range states {
+		hall, ok := assignment[states[i].ID]
+		if !ok {
+			continue
+		}
+
+		waits, _ := arrivalTimes(&states[i], hall, t)
+		for call, in := range waits {
+			arrivals[call] = ETA{Call: call, Car: states[i].ID, In: in}
+		}
+	}
+
+	var etas []ETA
+	for _, call := range activeHallCalls(hallCalls) {
+		eta, ok := arrivals[call]
+		if !ok {
+			eta = ETA{Call: call, Car: NoCar}
+		}
+		etas = append(etas, eta)
+	}
+
+	return etas
+}
+
+// Dispatcher runs an Assigner on a worldview and estimates when each hall call
+// is answered, so callers need not gather the calls and states themselves
+type Dispatcher struct {
+	Assigner Assigner
+	Timing   Timing
+}
+
+// Plan is one assignment of a worldview's hall calls
+type Plan struct {
+	// HallCalls are the confirmed hall calls the assignment was made from
+	HallCalls  [][2]statesync.HallCallPairState
+	States     []statesync.RemoteElevatorState
+	Assignment map[int][][2]bool
+	ETAs       []ETA
+}
+
+// Plan assigns the confirmed hall calls of the worldview to its alive
+// elevators and estimates their arrivals
+func (d *Dispatcher) Plan(wv *statesync.Worldview) Plan {
+	p := Plan{
+		HallCalls: wv.GetConfirmedHallCalls(),
+		States:    wv.GetAllElevatorStates(),
+	}
+	p.Assignment = d.Assigner.Assign(p.HallCalls, p.States)
+	p.ETAs = EstimateArrivals(p.Assignment, p.HallCalls, p.States, d.Timing)
+	return p
+}
+
+// ETAs returns an ETA for every confirmed hall call of the worldview, as
+// EstimateArrivals does
+func (d *Dispatcher) ETAs(wv *statesync.Worldview) []ETA {
+	return d.Plan(wv).ETAs
+}
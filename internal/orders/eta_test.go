@@ -0,0 +1,47 @@
+package orders
+
+import (
+	"testing"
+
+	statesync "github.com/Mosazghi/elevator-ttk4145/internal/sync"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEstimateArrivals(t *testing.T) {
+	a := &TimeToIdleAssigner{Timing: testTiming}
+	states := []statesync.RemoteElevatorState{*idleAt(1, 0), *idleAt(2, 3)}
+	hallCalls := hallCallsWith(
+		HallCall{Floor: 1, Dir: statesync.HDUp},
+		HallCall{Floor: 3, Dir: statesync.HDDown},
+	)
+
+	etas := EstimateArrivals(a.Assign(hallCalls, states), hallCalls, states, testTiming)
+
+	assert.Equal(t, []ETA{
+		{Call: HallCall{Floor: 1, Dir: statesync.HDUp}, Car: 1, In: testTiming.Travel},
+		{Call: HallCall{Floor: 3, Dir: statesync.HDDown}, Car: 2, In: 0},
+	}, etas)
+}
+
+func TestEstimateArrivals_NoCar(t *testing.T) {
+	independent := idleAt(1, 0)
+	independent.Independent = true
+	states := []statesync.RemoteElevatorState{*independent}
+	hallCalls := hallCallsWith(HallCall{Floor: 2, Dir: statesync.HDUp})
+
+	etas := EstimateArrivals((&TimeToIdleAssigner{Timing: testTiming}).Assign(hallCalls, states), hallCalls, states, testTiming)
+
+	assert.Equal(t, []ETA{{Call: HallCall{Floor: 2, Dir: statesync.HDUp}, Car: NoCar}}, etas)
+}
+
+func TestDispatcher_ETAs(t *testing.T) {
+	wv := statesync.NewWorldView(1, 4)
+	assert.NoError(t, wv.SetLocalElevator(idleAt(1, 0)))
+	assert.NoError(t, wv.SetHallCall(2, statesync.HDUp, statesync.HSAvailable))
+
+	d := &Dispatcher{Assigner: &TimeToIdleAssigner{Timing: testTiming}, Timing: testTiming}
+
+	assert.Equal(t, []ETA{
+		{Call: HallCall{Floor: 2, Dir: statesync.HDUp}, Car: 1, In: 2 * testTiming.Travel},
+	}, d.ETAs(wv))
+}
@@ -0,0 +1,200 @@
+package orders
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/Mosazghi/elevator-ttk4145/internal/elevator"
+	elevio "github.com/Mosazghi/elevator-ttk4145/internal/hw"
+	statesync "github.com/Mosazghi/elevator-ttk4145/internal/sync"
+)
+
+// HRAInput is the input format of the course's hall_request_assigner
+type HRAInput struct {
+	HallRequests [][2]bool               `json:"hallRequests"`
+	States       map[string]HRAElevState `json:"states"`
+}
+
+// HRAElevState is an elevator as hall_request_assigner sees it
+type HRAElevState struct {
+	Behaviour   string `json:"behaviour"`
+	Floor       int    `json:"floor"`
+	Direction   string `json:"direction"`
+	CabRequests []bool `json:"cabRequests"`
+}
+
+// HRAOutput is the output format of hall_request_assigner: the hall requests
+// each elevator should serve, keyed by elevator ID
+type HRAOutput map[string][][2]bool
+
+// Behaviour and direction names used by hall_request_assigner
+const (
+	hraIdle     = "idle"
+	hraMoving   = "moving"
+	hraDoorOpen = "doorOpen"
+
+	hraUp   = "up"
+	hraDown = "down"
+	hraStop = "stop"
+)
+
+// NewHRAInput encodes a worldview's hall calls and elevators. Elevators that
+// do not accept hall calls are left out, so they are never assigned any.
+func NewHRAInput(hallCalls [][2]statesync.HallCallPairState, states []statesync.RemoteElevatorState) HRAInput {
+	in := HRAInput{
+		HallRequests: make([][2]bool, len(hallCalls)),
+		States:       make(map[string]HRAElevState),
+	}
+
+	for floor := range hallCalls {
+		for dir, call := range hallCalls[floor] {
+			in.HallRequests[floor][dir] = call.State != statesync.HSNone
+		}
+	}
+
+	for _, s := range states {
+		if !s.AcceptsHallCalls() {
+			continue
+		}
+		in.States[strconv.Itoa(s.ID)] = newHRAElevState(&s, len(hallCalls))
+	}
+
+	return in
+}
+
+func newHRAElevState(s *statesync.RemoteElevatorState, numFloors int) HRAElevState {
+	res := HRAElevState{
+		Behaviour:   hraIdle,
+		Floor:       min(max(s.CurrentFloor, 0), numFloors-1),
+		Direction:   hraStop,
+		CabRequests: make([]bool, numFloors),
+	}
+	copy(res.CabRequests, s.CabCalls)
+
+	switch s.Behavior {
+	case elevator.BMoving:
+		res.Behaviour = hraMoving
+	case elevator.BDoorOpen:
+		res.Behaviour = hraDoorOpen
+	}
+
+	switch s.Direction {
+	case elevio.Up:
+		res.Direction = hraUp
+	case elevio.Down:
+		res.Direction = hraDown
+	}
+
+	return res
+}
+
+// Decode turns the input back into hall calls and elevator states. Elevator
+// IDs must be integers.
+func (in HRAInput) Decode() ([][2]statesync.HallCallPairState, []statesync.RemoteElevatorState, error) {
+	numFloors := len(in.HallRequests)
+	hallCalls := make([][2]statesync.HallCallPairState, numFloors)
+	for floor := range in.HallRequests {
+		for dir, active := range in.HallRequests[floor] {
+			if active {
+				hallCalls[floor][dir] = statesync.HallCallPairState{State: statesync.HSAvailable}
+			}
+		}
+	}
+
+	var states []statesync.RemoteElevatorState
+	for key, hs := range in.States {
+		id, err := strconv.Atoi(key)
+		if err != nil {
+			return nil, nil, fmt.Errorf("elevator ID %q is not an integer", key)
+		}
+
+		s, err := hs.decode(id, numFloors)
+		if err != nil {
+			return nil, nil, fmt.Errorf("elevator %q: %w", key, err)
+		}
+		states = append(states, *s)
+	}
+
+	return hallCalls, states, nil
+}
+
+func (hs HRAElevState) decode(id, numFloors int) (*statesync.RemoteElevatorState, error) {
+	s := statesync.NewRemoteElevatorState(id, numFloors)
+
+	if len(hs.CabRequests) != numFloors {
+		return nil, fmt.Errorf("cab requests length %d does not match number of floors %d", len(hs.CabRequests), numFloors)
+	}
+	copy(s.CabCalls, hs.CabRequests)
+
+	if hs.Floor < 0 || hs.Floor >= numFloors {
+		return nil, fmt.Errorf("floor %d is out of bounds", hs.Floor)
+	}
+	s.CurrentFloor = hs.Floor
+	s.TargetFloor = hs.Floor
+
+	switch hs.Behaviour {
+	case hraIdle:
+		s.Behavior = elevator.BIdle
+	case hraMoving:
+		s.Behavior = elevator.BMoving
+	case hraDoorOpen:
+		s.Behavior = elevator.BDoorOpen
+		s.DoorState = elevator.DSOpen
+	default:
+		return nil, fmt.Errorf("unknown behaviour %q", hs.Behaviour)
+	}
+
+	switch hs.Direction {
+	case hraUp:
+		s.Direction = elevio.Up
+	case hraDown:
+		s.Direction = elevio.Down
+	case hraStop:
+		s.Direction = elevio.Stop
+	default:
+		return nil, fmt.Errorf("unknown direction %q", hs.Direction)
+	}
+
+	return s, nil
+}
+
+// NewHRAOutput encodes an assignment
+func NewHRAOutput(assignment map[int][][2]bool) HRAOutput {
+	out := make(HRAOutput, len(assignment))
+	for id, hall := range assignment {
+		out[strconv.Itoa(id)] = hall
+	}
+	return out
+}
+
+// Decode turns the output back into an assignment keyed by elevator ID
+func (out HRAOutput) Decode() (map[int][][2]bool, error) {
+	assignment := make(map[int][][2]bool, len(out))
+	for key, hall := range out {
+		id, err := strconv.Atoi(key)
+		if err != nil {
+			return nil, fmt.Errorf("elevator ID %q is not an integer", key)
+		}
+		assignment[id] = hall
+	}
+	return assignment, nil
+}
+
+// ParseHRAInput reads hall_request_assigner input JSON
+func ParseHRAInput(data []byte) (HRAInput, error) {
+	var in HRAInput
+	if err := json.Unmarshal(data, &in); err != nil {
+		return in, fmt.Errorf("failed to parse assigner input: %w", err)
+	}
+	return in, nil
+}
+
+// ParseHRAOutput reads hall_request_assigner output JSON
+func ParseHRAOutput(data []byte) (HRAOutput, error) {
+	var out HRAOutput
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil, fmt.Errorf("failed to parse assigner output: %w", err)
+	}
+	return out, nil
+}
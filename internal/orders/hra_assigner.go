@@ -0,0 +1,449 @@
+package orders
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"slices"
+	"strconv"
+	"time"
+
+	elevio "github.com/Mosazghi/elevator-ttk4145/internal/hw"
+	statesync "github.com/Mosazghi/elevator-ttk4145/internal/sync"
+)
+
+// DefaultHRAExecutable is looked up in PATH when ExecAssigner has no Path
+const DefaultHRAExecutable = "hall_request_assigner"
+
+// HRAAssigner is a Go port of the course's hall_request_assigner. Given the
+// same input and durations it gives the same output as the executable.
+//
+// Every elevator is simulated one move at a time, always moving the one that
+// is furthest behind. A hall request goes to the first elevator to clear it.
+// Assign times each elevator with the durations it has learned, see
+// Timing.For; AssignHRA only has the input and uses Timing for all of them.
+type HRAAssigner struct {
+	Timing Timing
+}
+
+func (a *HRAAssigner) Assign(hallCalls [][2]statesync.HallCallPairState, states []statesync.RemoteElevatorState) map[int][][2]bool {
+	result := emptyAssignment(len(hallCalls), states)
+
+	timings := make(map[string]Timing, len(states))
+	for i := range states {
+		timings[strconv.Itoa(states[i].ID)] = a.Timing.For(&states[i])
+	}
+
+	assigned, err := a.assign(NewHRAInput(hallCalls, states), timings).Decode()
+	if err != nil {
+		// Cannot happen, the IDs were integers going in
+		return result
+	}
+
+	for id, hall := range assigned {
+		result[id] = hall
+	}
+	return result
+}
+
+// AssignHRA runs the assignment on hall_request_assigner input
+func (a *HRAAssigner) AssignHRA(in HRAInput) HRAOutput {
+	return a.assign(in, nil)
+}
+
+// assign runs the assignment with the given durations for each elevator ID,
+// falling back to a.Timing
+func (a *HRAAssigner) assign(in HRAInput, timings map[string]Timing) HRAOutput {
+	reqs := make([][2]hraReq, len(in.HallRequests))
+	for floor := range in.HallRequests {
+		for dir, active := range in.HallRequests[floor] {
+			reqs[floor][dir].active = active
+		}
+	}
+
+	states := hraInitialStates(in.States, timings, a.Timing)
+	for i := range states {
+		states[i].performInitialMove(reqs)
+	}
+
+	// Each move takes the hindmost elevator a floor or a door cycle further,
+	// so this is only a guard against malformed input
+	for range hraMaxMoves * max(len(reqs), 1) {
+		if len(states) == 0 {
+			break
+		}
+
+		slices.SortStableFunc(states, func(x, y hraState) int {
+			return cmp.Compare(x.time, y.time)
+		})
+
+		done := !hraAnyUnassigned(reqs)
+		if hraUnvisitedAreImmediatelyAssignable(reqs, states) {
+			hraAssignImmediate(reqs, states)
+			done = true
+		}
+
+		if done {
+			break
+		}
+
+		states[0].performSingleMove(reqs)
+	}
+
+	out := make(HRAOutput, len(in.States))
+	for id := range in.States {
+		out[id] = make([][2]bool, len(in.HallRequests))
+	}
+
+	for floor := range reqs {
+		for dir, req := range reqs[floor] {
+			if req.active && req.assignedTo != "" {
+				out[req.assignedTo][floor][dir] = true
+			}
+		}
+	}
+
+	return out
+}
+
+// ExecAssigner runs the course's hall_request_assigner executable. If the
+// executable fails, the native port is used instead.
+type ExecAssigner struct {
+	Path   string
+	Timing Timing
+}
+
+func (a *ExecAssigner) Assign(hallCalls [][2]statesync.HallCallPairState, states []statesync.RemoteElevatorState) map[int][][2]bool {
+	result, err := a.run(hallCalls, states)
+	if err != nil {
+		fmt.Printf("[ASSIGNER] %v, falling back to native assigner\n", err)
+		native := &HRAAssigner{Timing: a.Timing}
+		return native.Assign(hallCalls, states)
+	}
+	return result
+}
+
+func (a *ExecAssigner) run(hallCalls [][2]statesync.HallCallPairState, states []statesync.RemoteElevatorState) (map[int][][2]bool, error) {
+	input, err := json.Marshal(NewHRAInput(hallCalls, states))
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode assigner input: %w", err)
+	}
+
+	path := a.Path
+	if path == "" {
+		path = DefaultHRAExecutable
+	}
+
+	output, err := exec.Command(path,
+		"--input", string(input),
+		"--travelDuration", strconv.FormatInt(a.Timing.Travel.Milliseconds(), 10),
+		"--doorOpenDuration", strconv.FormatInt(a.Timing.DoorOpen.Milliseconds(), 10),
+	).Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to run %s: %w", path, err)
+	}
+
+	out, err := ParseHRAOutput(output)
+	if err != nil {
+		return nil, err
+	}
+
+	assigned, err := out.Decode()
+	if err != nil {
+		return nil, err
+	}
+
+	result := emptyAssignment(len(hallCalls), states)
+	for id, hall := range assigned {
+		if len(hall) != len(hallCalls) {
+			return nil, fmt.Errorf("assigner output for %d has %d floors, expected %d", id, len(hall), len(hallCalls))
+		}
+		result[id] = hall
+	}
+	return result, nil
+}
+
+const hraMaxMoves = 1000
+
+type hraReq struct {
+	active     bool
+	assignedTo string
+}
+
+type hraState struct {
+	id        string
+	behaviour string
+	floor     int
+	direction int
+	cab       []bool
+	time      time.Duration
+	timing    Timing
+}
+
+// hraInitialStates orders the elevators by ID and staggers their clocks by a
+// microsecond each, which makes every later tie go to the lower ID. An
+// elevator missing from timings is timed with fallback.
+func hraInitialStates(states map[string]HRAElevState, timings map[string]Timing, fallback Timing) []hraState {
+	ids := make([]string, 0, len(states))
+	for id := range states {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+
+	result := make([]hraState, len(ids))
+	for i, id := range ids {
+		s := states[id]
+		t, ok := timings[id]
+		if !ok {
+			t = fallback
+		}
+		result[i] = hraState{
+			id:        id,
+			behaviour: s.Behaviour,
+			floor:     s.Floor,
+			direction: hraDirection(s.Direction),
+			cab:       append([]bool(nil), s.CabRequests...),
+			time:      time.Duration(i) * time.Microsecond,
+			timing:    t,
+		}
+	}
+	return result
+}
+
+func hraDirection(dir string) int {
+	switch dir {
+	case hraUp:
+		return 1
+	case hraDown:
+		return -1
+	}
+	return 0
+}
+
+func hraAnyUnassigned(reqs [][2]hraReq) bool {
+	for floor := range reqs {
+		for _, req := range reqs[floor] {
+			if req.active && req.assignedTo == "" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (s *hraState) anyCab() bool {
+	return slices.Contains(s.cab, true)
+}
+
+// hraUnvisitedAreImmediatelyAssignable holds when every unassigned request is
+// at the floor of an elevator without cab requests, and no floor has both
+// directions requested
+func hraUnvisitedAreImmediatelyAssignable(reqs [][2]hraReq, states []hraState) bool {
+	for i := range states {
+		if states[i].anyCab() {
+			return false
+		}
+	}
+
+	for floor := range reqs {
+		if reqs[floor][0].active && reqs[floor][1].active {
+			return false
+		}
+		for _, req := range reqs[floor] {
+			if !req.active || req.assignedTo != "" {
+				continue
+			}
+			found := false
+			for i := range states {
+				if states[i].floor == floor && !states[i].anyCab() {
+					found = true
+				}
+			}
+			if !found {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func hraAssignImmediate(reqs [][2]hraReq, states []hraState) {
+	for floor := range reqs {
+		for dir := range reqs[floor] {
+			for i := range states {
+				req := &reqs[floor][dir]
+				if req.active && req.assignedTo == "" && states[i].floor == floor && !states[i].anyCab() {
+					req.assignedTo = states[i].id
+					states[i].time += states[i].timing.DoorOpen
+				}
+			}
+		}
+	}
+}
+
+func (s *hraState) performInitialMove(reqs [][2]hraReq) {
+	switch s.behaviour {
+	case hraDoorOpen:
+		s.time += s.timing.DoorOpen / 2
+		fallthrough
+	case hraIdle:
+		for dir := range reqs[s.floor] {
+			if reqs[s.floor][dir].active {
+				reqs[s.floor][dir].assignedTo = s.id
+				s.time += s.timing.DoorOpen
+			}
+		}
+	case hraMoving:
+		s.floor = min(max(s.floor+s.direction, 0), len(reqs)-1)
+		s.time += s.timing.travel(s.floor, elevio.MotorDirection(-s.direction)) / 2
+	}
+}
+
+func (s *hraState) performSingleMove(reqs [][2]hraReq) {
+	e := s.withUnassignedRequests(reqs)
+
+	onClear := func(button int) {
+		if button == hraCab {
+			s.cab[s.floor] = false
+		} else {
+			reqs[s.floor][button].assignedTo = s.id
+		}
+	}
+
+	switch s.behaviour {
+	case hraMoving:
+		if e.shouldStop() {
+			s.behaviour = hraDoorOpen
+			s.time += s.timing.DoorOpen
+			e.clearAtFloor(onClear)
+		} else {
+			s.time += s.timing.travel(s.floor, elevio.MotorDirection(s.direction))
+			s.floor += s.direction
+		}
+	default:
+		s.direction = e.chooseDirection()
+		if s.direction == 0 {
+			if e.anyAtFloor() {
+				s.time += s.timing.DoorOpen
+				e.clearAtFloor(onClear)
+				s.behaviour = hraDoorOpen
+			} else {
+				s.behaviour = hraIdle
+			}
+		} else {
+			s.behaviour = hraMoving
+			s.time += s.timing.travel(s.floor, elevio.MotorDirection(s.direction))
+			s.floor += s.direction
+		}
+	}
+}
+
+const hraCab = 2
+
+// hraElevator is an elevator with the requests it would go for, as seen by
+// the single-elevator algorithm hall_request_assigner simulates with
+type hraElevator struct {
+	floor     int
+	direction int
+	requests  [][3]bool
+}
+
+func (s *hraState) withUnassignedRequests(reqs [][2]hraReq) *hraElevator {
+	e := &hraElevator{
+		floor:     s.floor,
+		direction: s.direction,
+		requests:  make([][3]bool, len(reqs)),
+	}
+	for floor := range reqs {
+		for dir, req := range reqs[floor] {
+			e.requests[floor][dir] = req.active && req.assignedTo == ""
+		}
+		e.requests[floor][hraCab] = s.cab[floor]
+	}
+	return e
+}
+
+func (e *hraElevator) above() bool {
+	for floor := e.floor + 1; floor < len(e.requests); floor++ {
+		if e.requests[floor] != [3]bool{} {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *hraElevator) below() bool {
+	for floor := 0; floor < e.floor; floor++ {
+		if e.requests[floor] != [3]bool{} {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *hraElevator) anyAtFloor() bool {
+	return e.requests[e.floor] != [3]bool{}
+}
+
+func (e *hraElevator) shouldStop() bool {
+	r := e.requests[e.floor]
+	atEnd := e.floor == 0 || e.floor == len(e.requests)-1
+	switch e.direction {
+	case 1:
+		return r[0] || r[hraCab] || !e.above() || atEnd
+	case -1:
+		return r[1] || r[hraCab] || !e.below() || atEnd
+	}
+	return true
+}
+
+func (e *hraElevator) chooseDirection() int {
+	switch {
+	case e.direction == 1 && e.above():
+		return 1
+	case e.direction == 1 && e.anyAtFloor():
+		return 0
+	case e.direction == 1 && e.below():
+		return -1
+	case e.direction == 1:
+		return 0
+	case e.below():
+		return -1
+	case e.anyAtFloor():
+		return 0
+	case e.above():
+		return 1
+	}
+	return 0
+}
+
+// clearAtFloor clears the cab request and the hall request in the direction
+// of travel, or the other one if there is nothing further that way
+func (e *hraElevator) clearAtFloor(onClear func(button int)) {
+	clearButton := func(button int) {
+		if e.requests[e.floor][button] {
+			onClear(button)
+			e.requests[e.floor][button] = false
+		}
+	}
+
+	clearButton(hraCab)
+	switch e.direction {
+	case 1:
+		if e.requests[e.floor][0] {
+			clearButton(0)
+		} else if !e.above() {
+			clearButton(1)
+		}
+	case -1:
+		if e.requests[e.floor][1] {
+			clearButton(1)
+		} else if !e.below() {
+			clearButton(0)
+		}
+	default:
+		clearButton(0)
+		clearButton(1)
+	}
+}
@@ -0,0 +1,153 @@
+package orders
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/Mosazghi/elevator-ttk4145/internal/elevator"
+	elevio "github.com/Mosazghi/elevator-ttk4145/internal/hw"
+	statesync "github.com/Mosazghi/elevator-ttk4145/internal/sync"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// hall_request_assigner's default durations
+var hraTiming = Timing{Travel: 2500 * time.Millisecond, DoorOpen: 3 * time.Second}
+
+// The example from the hall_request_assigner README
+const hraExampleInput = `{
+	"hallRequests": [[false,false],[true,false],[false,false],[false,true]],
+	"states": {
+		"one": {"behaviour":"moving", "floor":2, "direction":"up", "cabRequests":[false,false,true,true]},
+		"two": {"behaviour":"idle", "floor":0, "direction":"stop", "cabRequests":[false,false,false,false]}
+	}
+}`
+
+const hraExampleOutput = `{
+	"one": [[false,false],[false,false],[false,false],[false,true]],
+	"two": [[false,false],[true,false],[false,false],[false,false]]
+}`
+
+func TestHRAAssigner_ReadmeExample(t *testing.T) {
+	in, err := ParseHRAInput([]byte(hraExampleInput))
+	require.NoError(t, err)
+	expected, err := ParseHRAOutput([]byte(hraExampleOutput))
+	require.NoError(t, err)
+
+	a := &HRAAssigner{Timing: hraTiming}
+
+	assert.Equal(t, expected, a.AssignHRA(in))
+}
+
+func TestHRAInput_RoundTrip(t *testing.T) {
+	hallCalls := make([][2]statesync.HallCallPairState, 4)
+	hallCalls[1][statesync.HDUp] = statesync.HallCallPairState{State: statesync.HSAvailable}
+	hallCalls[3][statesync.HDDown] = statesync.HallCallPairState{State: statesync.HSProcessing, By: 2}
+
+	moving := statesync.NewRemoteElevatorState(1, 4)
+	moving.CurrentFloor = 2
+	moving.TargetFloor = 3
+	moving.Behavior = elevator.BMoving
+	moving.Direction = elevio.Up
+	moving.CabCalls[3] = true
+
+	doorOpen := statesync.NewRemoteElevatorState(2, 4)
+	doorOpen.CurrentFloor = 0
+	doorOpen.Behavior = elevator.BDoorOpen
+	doorOpen.DoorState = elevator.DSOpen
+
+	independent := statesync.NewRemoteElevatorState(3, 4)
+	independent.CurrentFloor = 1
+	independent.Independent = true
+
+	in := NewHRAInput(hallCalls, []statesync.RemoteElevatorState{*moving, *doorOpen, *independent})
+
+	data, err := json.Marshal(in)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"hallRequests": [[false,false],[true,false],[false,false],[false,true]],
+		"states": {
+			"1": {"behaviour":"moving", "floor":2, "direction":"up", "cabRequests":[false,false,false,true]},
+			"2": {"behaviour":"doorOpen", "floor":0, "direction":"stop", "cabRequests":[false,false,false,false]}
+		}
+	}`, string(data), "independent elevator should be left out")
+
+	parsed, err := ParseHRAInput(data)
+	require.NoError(t, err)
+
+	decodedCalls, decodedStates, err := parsed.Decode()
+	require.NoError(t, err)
+
+	assert.Equal(t, statesync.HSAvailable, decodedCalls[1][statesync.HDUp].State)
+	assert.Equal(t, statesync.HSAvailable, decodedCalls[3][statesync.HDDown].State)
+	assert.Equal(t, statesync.HSNone, decodedCalls[0][statesync.HDUp].State)
+	require.Len(t, decodedStates, 2)
+	for _, s := range decodedStates {
+		require.NoError(t, statesync.ValidateStateRemote(&s))
+		if s.ID == 1 {
+			assert.Equal(t, elevator.BMoving, s.Behavior)
+			assert.Equal(t, elevio.Up, s.Direction)
+			assert.Equal(t, 2, s.CurrentFloor)
+			assert.Equal(t, moving.CabCalls, s.CabCalls)
+		}
+	}
+}
+
+func TestHRAInput_DecodeRejectsBadInput(t *testing.T) {
+	tests := map[string]string{
+		"non-integer ID":  `{"hallRequests":[[false,false],[false,false]],"states":{"one":{"behaviour":"idle","floor":0,"direction":"stop","cabRequests":[false,false]}}}`,
+		"floor too high":  `{"hallRequests":[[false,false],[false,false]],"states":{"1":{"behaviour":"idle","floor":2,"direction":"stop","cabRequests":[false,false]}}}`,
+		"short cab calls": `{"hallRequests":[[false,false],[false,false]],"states":{"1":{"behaviour":"idle","floor":0,"direction":"stop","cabRequests":[false]}}}`,
+		"bad behaviour":   `{"hallRequests":[[false,false],[false,false]],"states":{"1":{"behaviour":"running","floor":0,"direction":"stop","cabRequests":[false,false]}}}`,
+		"bad direction":   `{"hallRequests":[[false,false],[false,false]],"states":{"1":{"behaviour":"idle","floor":0,"direction":"sideways","cabRequests":[false,false]}}}`,
+	}
+
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			in, err := ParseHRAInput([]byte(data))
+			require.NoError(t, err)
+
+			_, _, err = in.Decode()
+			assert.Error(t, err)
+		})
+	}
+}
+
+// The native assigner gives the same answer through both entry points
+func TestHRAAssigner_MatchesJSONPath(t *testing.T) {
+	in, err := ParseHRAInput([]byte(`{
+		"hallRequests": [[true,false],[false,true],[true,false],[false,true]],
+		"states": {
+			"1": {"behaviour":"idle", "floor":3, "direction":"stop", "cabRequests":[false,false,false,false]},
+			"2": {"behaviour":"moving", "floor":1, "direction":"up", "cabRequests":[false,false,true,false]},
+			"3": {"behaviour":"doorOpen", "floor":0, "direction":"stop", "cabRequests":[false,true,false,false]}
+		}
+	}`))
+	require.NoError(t, err)
+
+	a := &HRAAssigner{Timing: hraTiming}
+	hallCalls, states, err := in.Decode()
+	require.NoError(t, err)
+
+	expected, err := a.AssignHRA(in).Decode()
+	require.NoError(t, err)
+
+	assert.Equal(t, expected, a.Assign(hallCalls, states))
+}
+
+func TestHRAAssigner_UsesLearnedTiming(t *testing.T) {
+	slow := idleAt(1, 0)
+	slow.SegmentTimes = []time.Duration{10 * time.Second, 10 * time.Second, 10 * time.Second}
+	// The cab call keeps the car from being handed the call on arrival, so
+	// the stop goes to whichever car gets there first
+	fast := idleAt(2, 3)
+	fast.CabCalls[0] = true
+	hallCalls := hallCallsWith(HallCall{Floor: 1, Dir: statesync.HDDown})
+
+	a := &HRAAssigner{Timing: hraTiming}
+	result := a.Assign(hallCalls, []statesync.RemoteElevatorState{*slow, *fast})
+
+	assert.True(t, result[2][1][statesync.HDDown], "two floors at the default pace beat one slow floor")
+	assert.False(t, result[1][1][statesync.HDDown])
+}
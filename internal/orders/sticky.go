@@ -0,0 +1,89 @@
+package orders
+
+import (
+	"time"
+
+	statesync "github.com/Mosazghi/elevator-ttk4145/internal/sync"
+)
+
+// StickyAssigner keeps hall calls with the elevator already processing them,
+// as recorded in HallCallPairState.By. The inner assigner may only move such a
+// call to an elevator that becomes idle at least Margin sooner with it, or when
+// the owner can no longer take hall calls. This stops calls from flapping
+// between cars with nearly equal cost.
+type StickyAssigner struct {
+	Inner  Assigner
+	Timing Timing
+	Margin time.Duration
+}
+
+// WithHysteresis wraps the assigner in a StickyAssigner. A zero margin leaves
+// the assigner as it is.
+func WithHysteresis(inner Assigner, t Timing, margin time.Duration) Assigner {
+	if margin <= 0 {
+		return inner
+	}
+	return &StickyAssigner{Inner: inner, Timing: t, Margin: margin}
+}
+
+func (a *StickyAssigner) Assign(hallCalls [][2]statesync.HallCallPairState, states []statesync.RemoteElevatorState) map[int][][2]bool {
+	result := a.Inner.Assign(hallCalls, states)
+
+	candidates := make(map[int]*statesync.RemoteElevatorState)
+	for _, s := range sortedCandidates(states) {
+		candidates[s.ID] = &s
+	}
+
+	for _, call := range activeHallCalls(hallCalls) {
+		current := hallCalls[call.Floor][call.Dir]
+		if current.State != statesync.HSProcessing {
+			continue
+		}
+
+		owner, ok := candidates[current.By]
+		if !ok {
+			// Lost or unavailable, let the newcomer have it
+			continue
+		}
+
+		newcomer := assignedTo(result, call)
+		if newcomer == nil || *newcomer == owner.ID {
+			continue
+		}
+
+		ownerCost := TimeToIdle(owner, asProcessing(result, owner.ID), call, a.Timing)
+		newcomerCost := TimeToIdle(candidates[*newcomer], asProcessing(result, *newcomer), call, a.Timing)
+
+		if newcomerCost+a.Margin > ownerCost {
+			result[*newcomer][call.Floor][call.Dir] = false
+			result[owner.ID][call.Floor][call.Dir] = true
+		}
+	}
+
+	return result
+}
+
+// assignedTo returns the ID of the elevator the call is assigned to, if any
+func assignedTo(assignment map[int][][2]bool, call HallCall) *int {
+	for id, hall := range assignment {
+		if hall[call.Floor][call.Dir] {
+			return &id
+		}
+	}
+	return nil
+}
+
+// asProcessing turns an elevator's assigned calls into the load TimeToIdle
+// expects
+func asProcessing(assignment map[int][][2]bool, id int) [][2]statesync.HallCallPairState {
+	hall := assignment[id]
+	result := make([][2]statesync.HallCallPairState, len(hall))
+	for floor := range hall {
+		for dir, assigned := range hall[floor] {
+			if assigned {
+				result[floor][dir] = statesync.HallCallPairState{State: statesync.HSProcessing, By: id}
+			}
+		}
+	}
+	return result
+}
@@ -0,0 +1,61 @@
+package orders
+
+import (
+	"testing"
+	"time"
+
+	statesync "github.com/Mosazghi/elevator-ttk4145/internal/sync"
+	"github.com/stretchr/testify/assert"
+)
+
+// Car 2 is one floor closer to the call car 1 is processing
+func stickySetup() ([][2]statesync.HallCallPairState, []statesync.RemoteElevatorState) {
+	hallCalls := make([][2]statesync.HallCallPairState, 4)
+	hallCalls[3][statesync.HDDown] = statesync.HallCallPairState{State: statesync.HSProcessing, By: 1}
+
+	return hallCalls, []statesync.RemoteElevatorState{*idleAt(1, 1), *idleAt(2, 2)}
+}
+
+func TestStickyAssigner_KeepsOwnerWithinMargin(t *testing.T) {
+	hallCalls, states := stickySetup()
+	inner := &TimeToIdleAssigner{Timing: testTiming}
+	a := WithHysteresis(inner, testTiming, testTiming.Travel+time.Millisecond)
+
+	assert.True(t, inner.Assign(hallCalls, states)[2][3][statesync.HDDown], "inner assigner prefers the closer car")
+
+	result := a.Assign(hallCalls, states)
+
+	assert.True(t, result[1][3][statesync.HDDown], "owner should keep the call")
+	assert.False(t, result[2][3][statesync.HDDown])
+}
+
+func TestStickyAssigner_MovesWhenBeatenByMargin(t *testing.T) {
+	hallCalls, states := stickySetup()
+	a := WithHysteresis(&TimeToIdleAssigner{Timing: testTiming}, testTiming, testTiming.Travel/2)
+
+	result := a.Assign(hallCalls, states)
+
+	assert.True(t, result[2][3][statesync.HDDown], "newcomer a whole floor closer should take over")
+	assert.False(t, result[1][3][statesync.HDDown])
+}
+
+func TestStickyAssigner_MovesWhenOwnerUnavailable(t *testing.T) {
+	hallCalls, states := stickySetup()
+	states[0].Independent = true
+	a := WithHysteresis(&TimeToIdleAssigner{Timing: testTiming}, testTiming, time.Hour)
+
+	result := a.Assign(hallCalls, states)
+
+	assert.True(t, result[2][3][statesync.HDDown])
+
+	// Owner missing entirely, e.g. lost
+	result = a.Assign(hallCalls, states[1:])
+
+	assert.True(t, result[2][3][statesync.HDDown])
+}
+
+func TestWithHysteresis_ZeroMargin(t *testing.T) {
+	inner := &TimeToIdleAssigner{Timing: testTiming}
+
+	assert.Same(t, inner, WithHysteresis(inner, testTiming, 0))
+}
@@ -0,0 +1,138 @@
+package orders
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"time"
+)
+
+// travelModelAlpha is the weight of a new observation in the running estimates
+const travelModelAlpha = 0.2
+
+// maxSampleRatio bounds how far off the current estimate a sample may be
+// before it is taken for a fault, like a stop or a held door, and dropped
+const maxSampleRatio = 5
+
+// TravelModel learns an elevator's travel time for each segment between
+// adjacent floors, and how long it spends at a stop, from observation. The
+// estimates start out at the configured timing.
+type TravelModel struct {
+	segments []time.Duration // segment i is between floor i and i+1
+	door     time.Duration
+}
+
+// travelModelFile is the persisted form of a TravelModel
+type travelModelFile struct {
+	SegmentsMs  []int64 `json:"segmentsMs"`
+	DoorCycleMs int64   `json:"doorCycleMs"`
+}
+
+func NewTravelModel(numFloors int, prior Timing) *TravelModel {
+	m := &TravelModel{
+		segments: make([]time.Duration, max(numFloors-1, 0)),
+		door:     prior.DoorOpen,
+	}
+	for i := range m.segments {
+		m.segments[i] = prior.Travel
+	}
+	return m
+}
+
+// LoadTravelModel reads a model saved by Save. An empty path or a missing
+// file gives a new model.
+func LoadTravelModel(path string, numFloors int, prior Timing) (*TravelModel, error) {
+	m := NewTravelModel(numFloors, prior)
+	if path == "" {
+		return m, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read travel model: %w", err)
+	}
+
+	var f travelModelFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("failed to parse travel model %s: %w", path, err)
+	}
+
+	if len(f.SegmentsMs) != len(m.segments) {
+		return nil, fmt.Errorf("travel model %s has %d segments, expected %d", path, len(f.SegmentsMs), len(m.segments))
+	}
+
+	for i, ms := range f.SegmentsMs {
+		if ms > 0 {
+			m.segments[i] = time.Duration(ms) * time.Millisecond
+		}
+	}
+	if f.DoorCycleMs > 0 {
+		m.door = time.Duration(f.DoorCycleMs) * time.Millisecond
+	}
+
+	return m, nil
+}
+
+// Save writes the model to path
+func (m *TravelModel) Save(path string) error {
+	f := travelModelFile{DoorCycleMs: m.door.Milliseconds()}
+	for _, d := range m.segments {
+		f.SegmentsMs = append(f.SegmentsMs, d.Milliseconds())
+	}
+
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode travel model: %w", err)
+	}
+
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write travel model: %w", err)
+	}
+
+	return nil
+}
+
+// ObserveSegment records a trip between two adjacent floors. It reports
+// whether the sample was used.
+func (m *TravelModel) ObserveSegment(from, to int, d time.Duration) bool {
+	if abs(from-to) != 1 || min(from, to) < 0 || max(from, to) > len(m.segments) {
+		return false
+	}
+
+	return observe(&m.segments[min(from, to)], d)
+}
+
+// ObserveDoorCycle records the time from the door opening at a stop until the
+// car is ready to leave. It reports whether the sample was used.
+func (m *TravelModel) ObserveDoorCycle(d time.Duration) bool {
+	return observe(&m.door, d)
+}
+
+func observe(estimate *time.Duration, d time.Duration) bool {
+	if d <= 0 || (*estimate > 0 && d > maxSampleRatio**estimate) {
+		return false
+	}
+
+	*estimate += time.Duration(travelModelAlpha * float64(d-*estimate))
+	return true
+}
+
+// Clone returns a copy of the model, for saving while this one keeps learning
+func (m *TravelModel) Clone() *TravelModel {
+	return &TravelModel{segments: m.Segments(), door: m.door}
+}
+
+// Segments returns the travel time estimate of each segment, bottom first
+func (m *TravelModel) Segments() []time.Duration {
+	return append([]time.Duration(nil), m.segments...)
+}
+
+// DoorCycle returns the estimated time spent at a stop
+func (m *TravelModel) DoorCycle() time.Duration {
+	return m.door
+}
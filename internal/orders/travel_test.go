@@ -0,0 +1,78 @@
+package orders
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	statesync "github.com/Mosazghi/elevator-ttk4145/internal/sync"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTravelModel_LearnsSegment(t *testing.T) {
+	m := NewTravelModel(4, testTiming)
+
+	for range 50 {
+		assert.True(t, m.ObserveSegment(2, 1, 3*time.Second))
+	}
+
+	assert.InDelta(t, float64(3*time.Second), float64(m.Segments()[1]), float64(10*time.Millisecond))
+	assert.Equal(t, testTiming.Travel, m.Segments()[0], "other segments should keep the prior")
+	assert.Equal(t, testTiming.DoorOpen, m.DoorCycle())
+}
+
+func TestTravelModel_DropsBadSamples(t *testing.T) {
+	m := NewTravelModel(4, testTiming)
+
+	assert.False(t, m.ObserveSegment(0, 2, time.Second), "not adjacent")
+	assert.False(t, m.ObserveSegment(3, 4, time.Second), "out of bounds")
+	assert.False(t, m.ObserveSegment(0, 1, time.Minute), "stopped on the way")
+	assert.False(t, m.ObserveDoorCycle(0))
+
+	assert.Equal(t, []time.Duration{testTiming.Travel, testTiming.Travel, testTiming.Travel}, m.Segments())
+}
+
+func TestTravelModel_Clone(t *testing.T) {
+	m := NewTravelModel(4, testTiming)
+	c := m.Clone()
+
+	assert.True(t, m.ObserveSegment(0, 1, 3*time.Second))
+	assert.True(t, m.ObserveDoorCycle(time.Second))
+
+	assert.Equal(t, testTiming.Travel, c.Segments()[0], "the copy should not learn with the model")
+	assert.Equal(t, testTiming.DoorOpen, c.DoorCycle())
+}
+
+func TestTravelModel_SaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "travel.json")
+	m := NewTravelModel(4, testTiming)
+	m.ObserveSegment(0, 1, 3*time.Second)
+	m.ObserveDoorCycle(4 * time.Second)
+	require.NoError(t, m.Save(path))
+
+	loaded, err := LoadTravelModel(path, 4, testTiming)
+
+	require.NoError(t, err)
+	assert.Equal(t, m.Segments(), loaded.Segments())
+	assert.Equal(t, m.DoorCycle(), loaded.DoorCycle())
+
+	_, err = LoadTravelModel(path, 5, testTiming)
+	assert.Error(t, err, "model for another building")
+
+	fresh, err := LoadTravelModel(filepath.Join(t.TempDir(), "missing.json"), 4, testTiming)
+	require.NoError(t, err)
+	assert.Equal(t, testTiming.DoorOpen, fresh.DoorCycle())
+}
+
+// The cost of a car is simulated with the times it has learned and shared
+func TestTimeToIdle_UsesSharedSegmentTimes(t *testing.T) {
+	s := idleAt(1, 0)
+	s.SegmentTimes = []time.Duration{time.Second, 5 * time.Second, time.Second}
+	s.DoorTime = 4 * time.Second
+	hallCalls := make([][2]statesync.HallCallPairState, 4)
+
+	cost := TimeToIdle(s, hallCalls, HallCall{Floor: 3, Dir: statesync.HDDown}, testTiming)
+
+	assert.Equal(t, 7*time.Second+4*time.Second, cost)
+}
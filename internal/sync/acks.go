@@ -0,0 +1,40 @@
+package statesync
+
+import "slices"
+
+// Ack sets are sorted, duplicate-free lists of elevator IDs. They are never
+// modified in place, so a HallCallPairState can be copied without copying
+// its acks.
+
+// withAck returns acks with id added
+func withAck(acks []int, id int) []int {
+	i, found := slices.BinarySearch(acks, id)
+	if found {
+		return acks
+	}
+	return slices.Insert(slices.Clone(acks), i, id)
+}
+
+// unionAcks returns the IDs in either set
+func unionAcks(a, b []int) []int {
+	result := slices.Concat(a, b)
+	slices.Sort(result)
+	return slices.Compact(result)
+}
+
+// sameCallState reports whether two views of a call are of the same change,
+// so that their acks count towards the same confirmation
+func sameCallState(a, b HallCallPairState) bool {
+	return a.State == b.State && a.By == b.By && a.Clock == b.Clock
+}
+
+// IsConfirmed reports whether every given elevator has acknowledged the
+// call's current state
+func (c HallCallPairState) IsConfirmed(alive []int) bool {
+	for _, id := range alive {
+		if _, found := slices.BinarySearch(c.Acks, id); !found {
+			return false
+		}
+	}
+	return true
+}
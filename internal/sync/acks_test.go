@@ -0,0 +1,107 @@
+package statesync
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Three nodes that have all heard from each other
+func threeNodes(t *testing.T) (*Worldview, *Worldview, *Worldview) {
+	wvs := []*Worldview{NewWorldView(1, 4), NewWorldView(2, 4), NewWorldView(3, 4)}
+	for _, a := range wvs {
+		for _, b := range wvs {
+			if a != b {
+				require.NoError(t, a.Merge(b))
+			}
+		}
+	}
+	requireValid(t, wvs...)
+	return wvs[0], wvs[1], wvs[2]
+}
+
+func TestAcks_ConfirmedOnceEveryoneHasSeenIt(t *testing.T) {
+	wv1, wv2, wv3 := threeNodes(t)
+	require.NoError(t, wv2.SetHallCall(1, HDUp, HSAvailable))
+
+	assert.Equal(t, HSNone, wv2.GetConfirmedHallCalls()[1][HDUp].State, "only the registering node has seen it")
+
+	require.NoError(t, wv1.Merge(wv2))
+	assert.Equal(t, []int{1, 2}, wv1.hallCalls[1][HDUp].Acks)
+	assert.Equal(t, HSNone, wv1.GetConfirmedHallCalls()[1][HDUp].State, "node 3 has not seen it")
+
+	require.NoError(t, wv3.Merge(wv1))
+	assert.Equal(t, HSAvailable, wv3.GetConfirmedHallCalls()[1][HDUp].State)
+
+	require.NoError(t, wv2.Merge(wv3))
+	assert.Equal(t, HSAvailable, wv2.GetConfirmedHallCalls()[1][HDUp].State, "acks should travel back")
+}
+
+func TestAcks_AloneConfirmsAtOnce(t *testing.T) {
+	wv := NewWorldView(1, 4)
+	require.NoError(t, wv.SetHallCall(2, HDDown, HSAvailable))
+	require.NoError(t, wv.SetDestCall(0, 2, HSAvailable))
+
+	assert.Equal(t, HSAvailable, wv.GetConfirmedHallCalls()[2][HDDown].State)
+	assert.Equal(t, HSAvailable, wv.GetConfirmedDestCalls()[0][2].State)
+}
+
+// Two nodes taking in the same call at once end up agreeing on it
+func TestAcks_ConcurrentRegistrationsPool(t *testing.T) {
+	wv1 := NewWorldView(1, 4)
+	wv2 := NewWorldView(2, 4)
+	require.NoError(t, wv1.Merge(wv2))
+	require.NoError(t, wv2.Merge(wv1))
+
+	require.NoError(t, wv1.SetHallCall(3, HDDown, HSAvailable))
+	require.NoError(t, wv2.SetHallCall(3, HDDown, HSAvailable))
+	require.NoError(t, wv1.Merge(wv2))
+
+	assert.Equal(t, HSAvailable, wv1.GetConfirmedHallCalls()[3][HDDown].State)
+}
+
+// A lagging peer's acks do not count for a state we have moved past, and
+// never roll the call back
+func TestAcks_LaggingPeerDoesNotRollBack(t *testing.T) {
+	wv1 := NewWorldView(1, 4)
+	wv2 := NewWorldView(2, 4)
+	wv1.hallCalls[0][HDUp] = HallCallPairState{State: HSProcessing, By: 1, Acks: []int{1}}
+	wv2.hallCalls[0][HDUp] = HallCallPairState{State: HSAvailable, By: 2, Acks: []int{1, 2}}
+	require.NoError(t, wv2.updateChecksum())
+
+	require.NoError(t, wv1.Merge(wv2))
+
+	assert.Equal(t, HallCallPairState{State: HSProcessing, By: 1, Acks: []int{1}}, wv1.hallCalls[0][HDUp])
+}
+
+// A call released from a lost elevator stays confirmed by the nodes that
+// had acknowledged it
+func TestAcks_ReleaseKeepsConfirmation(t *testing.T) {
+	wv1, wv2, wv3 := threeNodes(t)
+	require.NoError(t, wv3.SetHallCall(1, HDUp, HSAvailable))
+	require.NoError(t, wv3.SetHallCall(1, HDUp, HSProcessing))
+	require.NoError(t, wv1.Merge(wv3))
+	require.NoError(t, wv2.Merge(wv1))
+	require.NoError(t, wv1.Merge(wv2))
+	require.Equal(t, []int{1, 2, 3}, wv1.hallCalls[1][HDUp].Acks)
+
+	now := time.Now()
+	wv1.Members().Heartbeat(2, now.Add(2*NodeTimeoutDelay))
+	wv1.Members().Expire(now.Add(2 * NodeTimeoutDelay))
+	require.Equal(t, []int{3}, wv1.GetLostElevatorIDs())
+
+	call := wv1.GetConfirmedHallCalls()[1][HDUp]
+	assert.Equal(t, HSAvailable, call.State, "the released call should stay confirmed")
+	assert.Equal(t, []int{1, 2}, call.Acks, "the lost elevator's ack should not be kept")
+}
+
+func TestIsConfirmed(t *testing.T) {
+	c := HallCallPairState{State: HSAvailable, Acks: []int{1, 3}}
+
+	assert.True(t, c.IsConfirmed([]int{3, 1}))
+	assert.False(t, c.IsConfirmed([]int{1, 2, 3}))
+	assert.Equal(t, []int{1, 2, 3}, withAck(c.Acks, 2))
+	assert.Equal(t, []int{1, 3}, c.Acks, "acks should not be changed in place")
+}
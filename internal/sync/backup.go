@@ -0,0 +1,109 @@
+package statesync
+
+import (
+	"fmt"
+	"slices"
+)
+
+// CabBackup is a copy of another elevator's cab calls, kept so that they
+// survive it restarting. Clock and Run are the stamp and run of the elevator
+// state they were taken from.
+type CabBackup struct {
+	Calls []bool `json:"calls"`
+	Clock uint64 `json:"clock"`
+	Run   uint64 `json:"run"`
+}
+
+// RestoredCabCalls returns the channel the cab calls peers held for us
+// before we restarted are handed over on. A set is sent for each backup
+// newer than the ones already restored, replacing one not yet read.
+func (wv *Worldview) RestoredCabCalls() <-chan []bool {
+	return wv.restoreChan
+}
+
+// GetCabBackup returns the cab calls backed up for the given elevator, alive
+// or lost
+func (wv *Worldview) GetCabBackup(id int) (CabBackup, bool) {
+	wv.mu.Lock()
+	defer wv.mu.Unlock()
+
+	b, ok := wv.cabBackups[id]
+	b.Calls = slices.Clone(b.Calls)
+	return b, ok
+}
+
+// validateCabBackups checks that every backup fits a building with the given
+// number of floors
+func validateCabBackups(backups map[int]CabBackup, numFloors int) error {
+	for id, b := range backups {
+		if len(b.Calls) != numFloors {
+			return fmt.Errorf("cab backup of %d has %d floors, expected %d", id, len(b.Calls), numFloors)
+		}
+	}
+	return nil
+}
+
+// mergeCabBackups takes the sender's cab calls, if given, and the backups it
+// holds. The newest backup of each elevator is kept. The caller holds wv.mu.
+func (wv *Worldview) mergeCabBackups(backups map[int]CabBackup, sender *RemoteElevatorState) {
+	if sender != nil && !wv.awaitingRestore(sender) {
+		wv.backupCabCalls(sender.ID, CabBackup{Calls: sender.CabCalls, Clock: sender.Clock, Run: sender.Run})
+	}
+
+	for id, b := range backups {
+		if id == wv.localID {
+			wv.restoreCabCalls(b)
+			continue
+		}
+		wv.backupCabCalls(id, b)
+	}
+}
+
+// backupCabCalls stores the backup unless we hold a newer one. The caller
+// holds wv.mu.
+func (wv *Worldview) backupCabCalls(id int, b CabBackup) {
+	if known, ok := wv.cabBackups[id]; ok && known.Clock >= b.Clock {
+		return
+	}
+	wv.cabBackups[id] = CabBackup{Calls: slices.Clone(b.Calls), Clock: b.Clock, Run: b.Run}
+}
+
+// awaitingRestore reports whether we hold cab calls the sender had before it
+// restarted, and it has not merged them yet. Its fresh state must not
+// replace them until it has. The caller holds wv.mu.
+func (wv *Worldview) awaitingRestore(sender *RemoteElevatorState) bool {
+	known, ok := wv.cabBackups[sender.ID]
+	return ok && known.Run != sender.Run && wv.ackedBy[sender.ID] < wv.backupRev[sender.ID]
+}
+
+// restoreCabCalls restores our cab calls from before we restarted. Only a
+// backup taken in an earlier run is ours from then, one from this run merely
+// echoes calls we have now. The run tells them apart whatever the clocks of
+// the nodes say. Peers may hold backups of different age, so each one newer
+// than those restored so far is handed over, once the merge it came with is
+// committed. The caller holds wv.mu.
+func (wv *Worldview) restoreCabCalls(b CabBackup) {
+	if b.Run == wv.run || b.Clock <= wv.restoredClock {
+		return
+	}
+	wv.restoredClock = b.Clock
+
+	if slices.Contains(b.Calls, true) {
+		wv.restored = slices.Clone(b.Calls)
+	}
+}
+
+// handOverRestored sends the cab calls last restored on RestoredCabCalls. The
+// caller holds wv.mu.
+func (wv *Worldview) handOverRestored() {
+	if wv.restored == nil {
+		return
+	}
+
+	select {
+	case <-wv.restoreChan:
+	default:
+	}
+	wv.restoreChan <- wv.restored
+	wv.restored = nil
+}
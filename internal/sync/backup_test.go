@@ -0,0 +1,195 @@
+package statesync
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Peers keep a node's cab calls, also once it is lost
+func TestCabBackup_KeptForLostPeer(t *testing.T) {
+	wv1 := NewWorldView(1, 4)
+	wv2 := NewWorldView(2, 4)
+	wv2.SetCabCall(2, true)
+
+	require.NoError(t, wv1.Merge(wv2))
+	wv1.Members().Expire(time.Now().Add(2 * NodeTimeoutDelay))
+
+	b, ok := wv1.GetCabBackup(2)
+	require.True(t, ok)
+	assert.Equal(t, []bool{false, false, true, false}, b.Calls)
+	assert.Equal(t, []int{2}, wv1.GetLostElevatorIDs())
+}
+
+// A restarted node gets its cab calls back from a peer, once
+func TestCabBackup_RestoredOnRejoin(t *testing.T) {
+	wv1 := NewWorldView(1, 4)
+	wv2 := NewWorldView(2, 4)
+	wv2.SetCabCall(1, true)
+	wv2.SetCabCall(3, true)
+	require.NoError(t, wv1.Merge(wv2))
+
+	// A third peer learns the backup second hand
+	wv3 := NewWorldView(3, 4)
+	require.NoError(t, wv3.Merge(wv1))
+
+	restarted := NewWorldView(2, 4)
+	require.NoError(t, restarted.Merge(wv3))
+
+	select {
+	case calls := <-restarted.RestoredCabCalls():
+		assert.Equal(t, []bool{false, true, false, true}, calls)
+	default:
+		t.Fatal("cab calls were not restored")
+	}
+
+	require.NoError(t, restarted.Merge(wv1))
+	assert.Empty(t, restarted.RestoredCabCalls(), "cab calls should only be restored once")
+}
+
+// A backup of the calls a running node has now is not restored
+func TestCabBackup_EchoIsNotRestored(t *testing.T) {
+	wv1 := NewWorldView(1, 4)
+	wv2 := NewWorldView(2, 4)
+	wv2.SetCabCall(0, true)
+	require.NoError(t, wv1.Merge(wv2))
+
+	wv2.SetCabCall(0, false)
+	require.NoError(t, wv2.Merge(wv1))
+
+	assert.Empty(t, wv2.RestoredCabCalls())
+}
+
+// A newer backup is never replaced by an older one passed on by a peer
+func TestCabBackup_KeepsNewest(t *testing.T) {
+	wv1 := NewWorldView(1, 4)
+	wv2 := NewWorldView(2, 4)
+	wv3 := NewWorldView(3, 4)
+
+	wv3.SetCabCall(1, true)
+	require.NoError(t, wv2.Merge(wv3))
+	wv3.SetCabCall(1, false)
+	require.NoError(t, wv1.Merge(wv3))
+	require.NoError(t, wv1.Merge(wv2))
+
+	b, ok := wv1.GetCabBackup(3)
+	require.True(t, ok)
+	assert.Equal(t, []bool{false, false, false, false}, b.Calls)
+}
+
+// startElevator reports the fresh state of a just started elevator, as the
+// node does right away
+func startElevator(t *testing.T, wv *Worldview) {
+	t.Helper()
+	state := NewRemoteElevatorState(wv.localID, wv.numFloors)
+	state.CurrentFloor = 0
+	require.NoError(t, wv.SetLocalElevator(state))
+}
+
+// A restarted node heard from before it has heard from its peers does not
+// wipe the backup of its cab calls
+func TestCabBackup_RestoredWhenRestartedSendsFirst(t *testing.T) {
+	wv1 := NewWorldView(1, 4)
+	wv2 := NewWorldView(2, 4)
+	wv2.SetCabCall(1, true)
+	wv2.SetCabCall(3, true)
+	require.NoError(t, wv1.Merge(wv2))
+
+	restarted := NewWorldView(2, 4)
+	startElevator(t, restarted)
+	require.NoError(t, wv1.Merge(restarted))
+
+	b, ok := wv1.GetCabBackup(2)
+	require.True(t, ok)
+	assert.Equal(t, []bool{false, true, false, true}, b.Calls, "backup should be kept until restored")
+
+	require.NoError(t, restarted.Merge(wv1))
+
+	var calls []bool
+	select {
+	case calls = <-restarted.RestoredCabCalls():
+		assert.Equal(t, []bool{false, true, false, true}, calls)
+	default:
+		t.Fatal("cab calls were not restored")
+	}
+
+	// Once it has them, its own state is backed up again
+	state := NewRemoteElevatorState(2, 4)
+	state.CurrentFloor = 0
+	state.CabCalls = calls
+	state.CabCalls[1] = false
+	require.NoError(t, restarted.SetLocalElevator(state))
+	require.NoError(t, wv1.Merge(restarted))
+
+	b, _ = wv1.GetCabBackup(2)
+	assert.Equal(t, []bool{false, false, false, true}, b.Calls)
+}
+
+// The newest backup any peer holds is restored, whichever is heard from first
+func TestCabBackup_RestoresNewest(t *testing.T) {
+	wv1 := NewWorldView(1, 4)
+	wv2 := NewWorldView(2, 4)
+	wv3 := NewWorldView(3, 4)
+	wv2.SetCabCall(1, true)
+	require.NoError(t, wv1.Merge(wv2))
+	wv2.SetCabCall(3, true)
+	require.NoError(t, wv3.Merge(wv2))
+
+	restarted := NewWorldView(2, 4)
+	startElevator(t, restarted)
+	require.NoError(t, restarted.Merge(wv3))
+	require.NoError(t, restarted.Merge(wv1))
+
+	select {
+	case calls := <-restarted.RestoredCabCalls():
+		assert.Equal(t, []bool{false, true, false, true}, calls)
+	default:
+		t.Fatal("cab calls were not restored")
+	}
+	assert.Empty(t, restarted.RestoredCabCalls(), "an older backup should not be restored")
+}
+
+// A peer whose clock runs ahead stamps the backup later than the restarted
+// node's clock starts at. The run still tells the backup is from before.
+func TestCabBackup_RestoredWithPeerClockAhead(t *testing.T) {
+	wv1 := NewWorldView(1, 4)
+	wv1.clock += uint64(time.Hour)
+	wv1.SetCabCall(0, true)
+	wv2 := NewWorldView(2, 4)
+	require.NoError(t, wv2.Merge(wv1))
+	wv2.SetCabCall(2, true)
+	require.NoError(t, wv1.Merge(wv2))
+
+	restarted := NewWorldView(2, 4)
+	startElevator(t, restarted)
+	b, _ := wv1.GetCabBackup(2)
+	require.Less(t, restarted.clock, b.Clock, "the backup should be stamped ahead of the restarted clock")
+
+	require.NoError(t, wv1.Merge(restarted))
+	require.NoError(t, restarted.Merge(wv1))
+
+	var calls []bool
+	select {
+	case calls = <-restarted.RestoredCabCalls():
+		assert.Equal(t, []bool{false, false, true, false}, calls)
+	default:
+		t.Fatal("cab calls were not restored")
+	}
+
+	// Its states from after the restore replace the backup
+	state := NewRemoteElevatorState(2, 4)
+	state.CurrentFloor = 0
+	state.CabCalls = calls
+	require.NoError(t, restarted.SetLocalElevator(state))
+	require.NoError(t, wv1.Merge(restarted))
+
+	served := NewRemoteElevatorState(2, 4)
+	served.CurrentFloor = 2
+	require.NoError(t, restarted.SetLocalElevator(served))
+	require.NoError(t, wv1.Merge(restarted))
+
+	b, _ = wv1.GetCabBackup(2)
+	assert.Equal(t, []bool{false, false, false, false}, b.Calls)
+}
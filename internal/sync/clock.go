@@ -0,0 +1,57 @@
+package statesync
+
+import "cmp"
+
+// The worldview keeps a Lamport clock. Every local change to a call or to the
+// local elevator state is stamped with the next tick, and every snapshot
+// merged moves the clock past all the stamps in it, so a change made after
+// seeing another always carries a higher stamp.
+
+// tick advances the clock for a local change. The caller holds wv.mu.
+func (wv *Worldview) tick() uint64 {
+	wv.clock++
+	return wv.clock
+}
+
+// observe moves the clock past every stamp in the snapshot. The caller holds
+// wv.mu.
+func (wv *Worldview) observe(s *WorldviewSnapshot) {
+	for floor := range s.HallCalls {
+		for _, call := range s.HallCalls[floor] {
+			wv.observeClock(call.Clock)
+		}
+	}
+
+	for from := range s.DestCalls {
+		for _, call := range s.DestCalls[from] {
+			wv.observeClock(call.Clock)
+		}
+	}
+
+	for _, state := range s.ElevatorStates {
+		wv.observeClock(state.Clock)
+	}
+
+	// A restarted node must stamp its state past the backup of its old one
+	for _, b := range s.CabBackups {
+		wv.observeClock(b.Clock)
+	}
+}
+
+// observeClock moves the clock past a single stamp. The caller holds wv.mu.
+func (wv *Worldview) observeClock(stamp uint64) {
+	wv.clock = max(wv.clock, stamp)
+}
+
+// supersedes reports whether c wins over o when two views of a call are
+// joined. The later stamp wins. Concurrent changes with the same stamp are
+// ordered by state, so that the one keeping the call alive wins, and then by
+// elevator ID. This is a total order, so the join does not depend on the
+// order views arrive in.
+func (c HallCallPairState) supersedes(o HallCallPairState) bool {
+	return cmp.Or(
+		cmp.Compare(c.Clock, o.Clock),
+		cmp.Compare(c.State, o.State),
+		cmp.Compare(c.By, o.By),
+	) > 0
+}
@@ -0,0 +1,48 @@
+package statesync
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// An old view of a call relayed by another peer does not undo the newer one
+func TestMerge_RelayedStaleViewDoesNotUndo(t *testing.T) {
+	wv1, wv2, wv3 := threeNodes(t)
+
+	require.NoError(t, wv2.SetHallCall(1, HDDown, HSAvailable))
+	require.NoError(t, wv3.Merge(wv2))
+	require.NoError(t, wv2.SetHallCall(1, HDDown, HSProcessing))
+
+	require.NoError(t, wv1.Merge(wv2))
+	require.NoError(t, wv1.Merge(wv3))
+
+	assert.Equal(t, HSProcessing, wv1.hallCalls[1][HDDown].State)
+	assert.Equal(t, 2, wv1.hallCalls[1][HDDown].By)
+}
+
+// A change made after merging is stamped later than everything merged
+func TestMerge_AdvancesClock(t *testing.T) {
+	wv1 := NewWorldView(1, 4)
+	wv2 := NewWorldView(2, 4)
+	wv2.clock = wv1.clock + 100
+	require.NoError(t, wv2.SetHallCall(0, HDUp, HSAvailable))
+
+	require.NoError(t, wv1.Merge(wv2))
+	require.NoError(t, wv1.SetHallCall(0, HDUp, HSProcessing))
+
+	assert.Greater(t, wv1.hallCalls[0][HDUp].Clock, wv2.hallCalls[0][HDUp].Clock)
+
+	require.NoError(t, wv2.Merge(wv1))
+	assert.Equal(t, HallCallPairState{State: HSProcessing, By: 1, Clock: wv1.hallCalls[0][HDUp].Clock, Acks: []int{1, 2}}, wv2.hallCalls[0][HDUp])
+}
+
+func TestSupersedes(t *testing.T) {
+	a := HallCallPairState{State: HSAvailable, By: 1, Clock: 3}
+
+	assert.True(t, HallCallPairState{State: HSNone, Clock: 4}.supersedes(a), "later stamp wins")
+	assert.True(t, HallCallPairState{State: HSProcessing, By: 1, Clock: 3}.supersedes(a), "call kept alive on a tie")
+	assert.True(t, HallCallPairState{State: HSAvailable, By: 2, Clock: 3}.supersedes(a), "higher ID breaks the tie")
+	assert.False(t, a.supersedes(a))
+}
@@ -4,4 +4,9 @@ import "time"
 
 const (
 	NodeTimeoutDelay = time.Second * 5
+	// BroadcastPeriod is how often a node sends its worldview to its peers
+	BroadcastPeriod = 100 * time.Millisecond
+	// FullSyncPeriod is how often a node sends its whole worldview instead of
+	// a delta, to repair anything the deltas missed
+	FullSyncPeriod = time.Second
 )
@@ -0,0 +1,340 @@
+package statesync
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"time"
+
+	"github.com/Mosazghi/elevator-ttk4145/shared/checksum"
+)
+
+// Every change to the worldview's content bumps its revision, and each call,
+// the local elevator state and each cab backup remember the revision they
+// last changed at. Peers report the revision of ours they have merged in
+// their own messages, so a node only has to send what changed since the
+// oldest revision any alive peer has. A delta carrying a base the receiver
+// has not reached means it missed a change, and it asks for a full snapshot.
+
+// HallCallUpdate is a hall call changed since a delta's base
+type HallCallUpdate struct {
+	Floor int               `json:"floor"`
+	Dir   HallCallDir       `json:"dir"`
+	Call  HallCallPairState `json:"call"`
+}
+
+// DestCallUpdate is a destination call changed since a delta's base
+type DestCallUpdate struct {
+	From int               `json:"from"`
+	To   int               `json:"to"`
+	Call HallCallPairState `json:"call"`
+}
+
+// WorldviewDelta holds what changed in the sender's worldview from revision
+// Base to Rev. Sender is only set if the sender's own elevator state changed.
+type WorldviewDelta struct {
+	Version    int                  `json:"version"`
+	SenderID   int                  `json:"senderId"`
+	Seq        uint64               `json:"seq"`
+	NumFloors  int                  `json:"numFloors"`
+	Base       uint64               `json:"base"`
+	Rev        uint64               `json:"rev"`
+	HallCalls  []HallCallUpdate     `json:"hallCalls,omitempty"`
+	DestCalls  []DestCallUpdate     `json:"destCalls,omitempty"`
+	Sender     *RemoteElevatorState `json:"sender,omitempty"`
+	CabBackups map[int]CabBackup    `json:"cabBackups,omitempty"`
+	Acks       map[int]uint64       `json:"acks,omitempty"`
+	// Checksum covers every other field
+	Checksum uint64 `json:"checksum"`
+}
+
+// recordChanges bumps the revision and stamps every part of the content that
+// differs from the content last recorded. The caller holds wv.mu.
+func (wv *Worldview) recordChanges(content *WorldviewSnapshot) {
+	prev := wv.prevContent
+	wv.rev++
+
+	for floor := range content.HallCalls {
+		for dir, call := range content.HallCalls[floor] {
+			if floor >= len(prev.HallCalls) || !reflect.DeepEqual(call, prev.HallCalls[floor][dir]) {
+				wv.hallRev[floor][dir] = wv.rev
+			}
+		}
+	}
+
+	for from := range content.DestCalls {
+		for to, call := range content.DestCalls[from] {
+			if from >= len(prev.DestCalls) || !reflect.DeepEqual(call, prev.DestCalls[from][to]) {
+				wv.destRev[from][to] = wv.rev
+			}
+		}
+	}
+
+	local := *wv.localRemoteState
+	local.LastSeenAt = time.Time{}
+	if !reflect.DeepEqual(local, wv.prevLocal) {
+		wv.localRev = wv.rev
+		wv.prevLocal = local
+	}
+
+	for id, b := range content.CabBackups {
+		if known, ok := prev.CabBackups[id]; !ok || !reflect.DeepEqual(b, known) {
+			wv.backupRev[id] = wv.rev
+		}
+	}
+
+	wv.prevContent = *content
+}
+
+// acksLocked returns the revision of each peer we have merged. The caller
+// holds wv.mu.
+func (wv *Worldview) acksLocked() map[int]uint64 {
+	if len(wv.peerRev) == 0 {
+		return nil
+	}
+	acks := make(map[int]uint64, len(wv.peerRev))
+	for id, rev := range wv.peerRev {
+		acks[id] = rev
+	}
+	return acks
+}
+
+// trackPeer records the revision of the sender we have merged, and the
+// revision of ours it reports having. The caller holds wv.mu.
+func (wv *Worldview) trackPeer(id int, rev uint64, acks map[int]uint64) {
+	wv.peerRev[id] = max(wv.peerRev[id], rev)
+	wv.ackedBy[id] = acks[wv.localID]
+}
+
+// deltaBase is the oldest revision of ours any alive peer has merged, or 0 if
+// one of them has merged none. Alone, there is nothing to send. The caller
+// holds wv.mu.
+func (wv *Worldview) deltaBase(alive []int) uint64 {
+	base := wv.rev
+	for _, id := range alive {
+		base = min(base, wv.ackedBy[id])
+	}
+	return base
+}
+
+// deltaLocked takes a sealed delta of everything changed since base, with
+// the next sequence number. The caller holds wv.mu.
+func (wv *Worldview) deltaLocked(base uint64) (WorldviewDelta, error) {
+	wv.seq++
+	d := WorldviewDelta{
+		Version:   SnapshotVersion,
+		SenderID:  wv.localID,
+		Seq:       wv.seq,
+		NumFloors: wv.numFloors,
+		Base:      base,
+		Rev:       wv.rev,
+		Acks:      wv.acksLocked(),
+	}
+
+	for floor := range wv.hallCalls {
+		for dir, call := range wv.hallCalls[floor] {
+			if wv.hallRev[floor][dir] > base {
+				d.HallCalls = append(d.HallCalls, HallCallUpdate{Floor: floor, Dir: HallCallDir(dir), Call: call})
+			}
+		}
+	}
+
+	for from := range wv.destCalls {
+		for to, call := range wv.destCalls[from] {
+			if wv.destRev[from][to] > base {
+				d.DestCalls = append(d.DestCalls, DestCallUpdate{From: from, To: to, Call: call})
+			}
+		}
+	}
+
+	if wv.localRev > base {
+		local := *wv.localRemoteState
+		local.CabCalls = slices.Clone(local.CabCalls)
+		local.SegmentTimes = slices.Clone(local.SegmentTimes)
+		d.Sender = &local
+	}
+
+	for id, b := range wv.cabBackups {
+		if wv.backupRev[id] > base {
+			if d.CabBackups == nil {
+				d.CabBackups = make(map[int]CabBackup)
+			}
+			d.CabBackups[id] = CabBackup{Calls: slices.Clone(b.Calls), Clock: b.Clock, Run: b.Run}
+		}
+	}
+
+	return d, d.seal()
+}
+
+// MergeDelta merges a peer's delta into the current worldview. A delta
+// building on a revision of the sender we have not merged is not applied,
+// and a full snapshot is asked for instead. Like a snapshot, an invalid
+// delta is rejected as a whole.
+func (wv *Worldview) MergeDelta(d *WorldviewDelta) error {
+	wv.mu.Lock()
+	defer wv.unlockAndDispatch()
+
+	return wv.mergeDelta(d)
+}
+
+// mergeDelta does the work of MergeDelta. The caller holds wv.mu.
+func (wv *Worldview) mergeDelta(d *WorldviewDelta) error {
+	if d == nil {
+		return fmt.Errorf("cannot merge with nil delta")
+	}
+
+	if d.Version != SnapshotVersion {
+		return fmt.Errorf("delta version %d is not supported, expected %d", d.Version, SnapshotVersion)
+	}
+
+	if d.SenderID == wv.localID {
+		return fmt.Errorf("cannot merge own delta")
+	}
+
+	if err := d.verify(); err != nil {
+		return err
+	}
+
+	if err := d.validate(wv.numFloors); err != nil {
+		return fmt.Errorf("invalid delta from %d: %w", d.SenderID, err)
+	}
+
+	if d.Seq <= wv.lastSeq[d.SenderID] {
+		// Reordered or duplicated on the way
+		return nil
+	}
+	wv.lastSeq[d.SenderID] = d.Seq
+	wv.heard(d.SenderID)
+
+	if d.Base > wv.peerRev[d.SenderID] {
+		// We missed a change somewhere between what we have and the base
+		if !slices.Contains(wv.resyncs, d.SenderID) {
+			wv.resyncs = append(wv.resyncs, d.SenderID)
+		}
+		wv.ackedBy[d.SenderID] = d.Acks[wv.localID]
+		return nil
+	}
+
+	next := wv.staged()
+	for _, u := range d.HallCalls {
+		next.observeClock(u.Call.Clock)
+	}
+	for _, u := range d.DestCalls {
+		next.observeClock(u.Call.Clock)
+	}
+	if d.Sender != nil {
+		next.observeClock(d.Sender.Clock)
+	}
+	for _, b := range d.CabBackups {
+		next.observeClock(b.Clock)
+	}
+
+	next.takeSenderState(d.SenderID, d.Sender)
+	// Before the backups, which are kept until the sender has merged them
+	next.trackPeer(d.SenderID, d.Rev, d.Acks)
+	next.mergeCabBackups(d.CabBackups, d.Sender)
+
+	for _, u := range d.HallCalls {
+		next.mergeCall(&next.hallCalls[u.Floor][u.Dir], u.Call)
+	}
+	for _, u := range d.DestCalls {
+		next.mergeCall(&next.destCalls[u.From][u.To], u.Call)
+	}
+
+	if err := next.validateLocked(anyElevator); err != nil {
+		return fmt.Errorf("worldview invalid after merging %d: %w", d.SenderID, err)
+	}
+
+	wv.commit(next)
+	return nil
+}
+
+// validate checks that everything in the delta fits our building
+func (d *WorldviewDelta) validate(numFloors int) error {
+	if d.NumFloors != numFloors {
+		return fmt.Errorf("number of floors doesnt match")
+	}
+
+	for _, u := range d.HallCalls {
+		if !IsValidFloor(u.Floor, numFloors) || (u.Dir != HDUp && u.Dir != HDDown) {
+			return fmt.Errorf("hall call update for floor %d dir %d is out of range", u.Floor, u.Dir)
+		}
+		if errs := checkCall(fmt.Sprintf("hallCalls[%d][%d]", u.Floor, u.Dir), u.Call, anyElevator); len(errs) > 0 {
+			return errors.Join(errs...)
+		}
+	}
+
+	for _, u := range d.DestCalls {
+		if !IsValidFloor(u.From, numFloors) || !IsValidFloor(u.To, numFloors) {
+			return fmt.Errorf("destination call update %d -> %d is out of range", u.From, u.To)
+		}
+		if errs := checkDestCall(fmt.Sprintf("destCalls[%d][%d]", u.From, u.To), u.From, u.To, u.Call, anyElevator); len(errs) > 0 {
+			return errors.Join(errs...)
+		}
+	}
+
+	if d.Sender != nil {
+		if d.Sender.ID != d.SenderID {
+			return fmt.Errorf("sender state belongs to %d", d.Sender.ID)
+		}
+		if err := ValidateStateRemote(d.Sender); err != nil {
+			return fmt.Errorf("%v's local state is invalid: %w", d.SenderID, err)
+		}
+	}
+
+	return validateCabBackups(d.CabBackups, numFloors)
+}
+
+// requestResync makes the next broadcast a full snapshot
+func (wv *Worldview) requestResync() {
+	wv.mu.Lock()
+	defer wv.mu.Unlock()
+
+	wv.forceFull = true
+}
+
+// takeResyncs returns the peers to ask for a full snapshot, and forgets them
+func (wv *Worldview) takeResyncs() []int {
+	wv.mu.Lock()
+	defer wv.mu.Unlock()
+
+	ids := wv.resyncs
+	wv.resyncs = nil
+	return ids
+}
+
+func (d *WorldviewDelta) computeChecksum() (uint64, error) {
+	content := *d
+	content.Checksum = 0
+
+	cs, err := checksum.CalculateChecksum(content)
+	if err != nil {
+		return 0, fmt.Errorf("failed to calculate checksum: %w", err)
+	}
+
+	return cs, nil
+}
+
+func (d *WorldviewDelta) seal() error {
+	cs, err := d.computeChecksum()
+	if err != nil {
+		return err
+	}
+
+	d.Checksum = cs
+	return nil
+}
+
+func (d *WorldviewDelta) verify() error {
+	cs, err := d.computeChecksum()
+	if err != nil {
+		return err
+	}
+
+	if cs != d.Checksum {
+		return fmt.Errorf("data integrity check failed: checksum mismatch")
+	}
+
+	return nil
+}
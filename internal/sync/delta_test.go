@@ -0,0 +1,145 @@
+package statesync
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// send broadcasts from one worldview to another and returns what was sent
+func send(t *testing.T, from, to *Worldview) message {
+	t.Helper()
+
+	data, err := from.encode()
+	require.NoError(t, err)
+	require.NoError(t, to.receive(data))
+
+	var msg message
+	require.NoError(t, json.Unmarshal(data, &msg))
+	return msg
+}
+
+// exchange lets two worldviews hear from each other until neither has
+// anything new to tell
+func exchange(t *testing.T, a, b *Worldview) {
+	t.Helper()
+	for range 3 {
+		send(t, a, b)
+		send(t, b, a)
+	}
+	requireValid(t, a, b)
+}
+
+func TestDelta_CarriesOnlyChanges(t *testing.T) {
+	wv1 := NewWorldView(1, 4)
+	wv2 := NewWorldView(2, 4)
+	exchange(t, wv1, wv2)
+
+	require.NoError(t, wv2.SetHallCall(2, HDDown, HSAvailable))
+	msg := send(t, wv2, wv1)
+
+	require.NotNil(t, msg.Delta, "peer is up to date, a delta should do")
+	assert.Nil(t, msg.Full)
+	require.Len(t, msg.Delta.HallCalls, 1)
+	assert.Equal(t, HallCallUpdate{Floor: 2, Dir: HDDown, Call: wv2.hallCalls[2][HDDown]}, msg.Delta.HallCalls[0])
+	assert.Nil(t, msg.Delta.Sender, "elevator state has not changed")
+	assert.Equal(t, HSAvailable, wv1.hallCalls[2][HDDown].State)
+
+	wv2.SetCabCall(1, true)
+	msg = send(t, wv2, wv1)
+	require.NotNil(t, msg.Delta)
+	require.NotNil(t, msg.Delta.Sender)
+	assert.True(t, wv1.elevatorStates[2].CabCalls[1])
+}
+
+// A lost delta is made up for by the next one, which builds on what the
+// receiver has acknowledged
+func TestDelta_LostDeltaIsResent(t *testing.T) {
+	wv1 := NewWorldView(1, 4)
+	wv2 := NewWorldView(2, 4)
+	exchange(t, wv1, wv2)
+
+	require.NoError(t, wv2.SetHallCall(0, HDUp, HSAvailable))
+	_, err := wv2.encode()
+	require.NoError(t, err)
+
+	require.NoError(t, wv2.SetHallCall(3, HDDown, HSAvailable))
+	msg := send(t, wv2, wv1)
+
+	require.NotNil(t, msg.Delta)
+	assert.Len(t, msg.Delta.HallCalls, 2)
+	assert.Equal(t, HSAvailable, wv1.hallCalls[0][HDUp].State)
+	assert.Equal(t, HSAvailable, wv1.hallCalls[3][HDDown].State)
+}
+
+// A node that gets a delta building on something it never had asks for a
+// full snapshot
+func TestDelta_GapTriggersResync(t *testing.T) {
+	wv1 := NewWorldView(1, 4)
+	wv2 := NewWorldView(2, 4)
+	exchange(t, wv1, wv2)
+	require.NoError(t, wv2.SetHallCall(1, HDUp, HSAvailable))
+
+	// Restarted, so it has merged nothing of node 2 yet
+	wv1 = NewWorldView(1, 4)
+	msg := send(t, wv2, wv1)
+	require.NotNil(t, msg.Delta)
+	assert.Equal(t, HSNone, wv1.hallCalls[1][HDUp].State, "delta over a gap should not be applied")
+
+	resyncs, err := wv1.encodeResyncs()
+	require.NoError(t, err)
+	require.Len(t, resyncs, 1)
+	require.NoError(t, wv2.receive(resyncs[0]))
+
+	msg = send(t, wv2, wv1)
+	require.NotNil(t, msg.Full, "asked for a full snapshot")
+	assert.Equal(t, HSAvailable, wv1.hallCalls[1][HDUp].State)
+
+	resyncs, err = wv1.encodeResyncs()
+	require.NoError(t, err)
+	assert.Empty(t, resyncs)
+}
+
+func TestDelta_PeriodicFullSnapshot(t *testing.T) {
+	wv1 := NewWorldView(1, 4)
+	wv2 := NewWorldView(2, 4)
+	exchange(t, wv1, wv2)
+
+	require.NotNil(t, send(t, wv2, wv1).Delta)
+
+	wv2.lastFull = time.Now().Add(-FullSyncPeriod)
+	require.NotNil(t, send(t, wv2, wv1).Full)
+	require.NotNil(t, send(t, wv2, wv1).Delta)
+}
+
+func TestMergeDelta_RejectsBadDeltas(t *testing.T) {
+	wv1 := NewWorldView(1, 4)
+	wv2 := NewWorldView(2, 4)
+
+	wv2.mu.Lock()
+	d, err := wv2.deltaLocked(1)
+	wv2.mu.Unlock()
+	require.NoError(t, err)
+
+	tampered := d
+	tampered.Rev++
+	assert.ErrorContains(t, wv1.MergeDelta(&tampered), "checksum mismatch")
+
+	for _, floor := range []int{-1, 4} {
+		outOfRange := d
+		outOfRange.HallCalls = []HallCallUpdate{{Floor: floor, Dir: HDUp}}
+		require.NoError(t, outOfRange.seal())
+		assert.Error(t, wv1.MergeDelta(&outOfRange), "floor %d", floor)
+	}
+
+	badCall := d
+	badCall.HallCalls = []HallCallUpdate{{Floor: 1, Dir: HDUp, Call: HallCallPairState{State: HSProcessing}}}
+	require.NoError(t, badCall.seal())
+	assert.ErrorContains(t, wv1.MergeDelta(&badCall), "hallCalls[1][0].By: call is processed by unknown elevator 0")
+
+	assert.Error(t, wv2.MergeDelta(&d), "own delta")
+	assert.Error(t, wv1.MergeDelta(nil))
+}
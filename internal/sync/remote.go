@@ -15,8 +15,25 @@ type RemoteElevatorState struct {
 	DoorState    elevator.DoorState
 	CabCalls     []bool
 	Behavior     elevator.Behavior
-	LastSeenAt   time.Time
-	NumFloors    int
+	// LastSeenAt is when the state was last heard from, by the receiver's
+	// clock. It is not sent.
+	LastSeenAt time.Time `json:"-"`
+	NumFloors  int
+	// Independent is set while the car is in independent service mode and
+	// must not be given hall calls
+	Independent bool
+	// Full is set while the car is loaded above its full-load threshold
+	Full bool
+	// SegmentTimes and DoorTime are the car's learned travel time between
+	// each pair of adjacent floors, bottom first, and time spent at a stop.
+	// Unset until the car has shared them.
+	SegmentTimes []time.Duration
+	DoorTime     time.Duration
+	// Clock is the Lamport time the state was last changed by its elevator
+	Clock uint64
+	// Run is picked at random every time its elevator's node starts. Cab
+	// calls backed up from another run are from before it restarted.
+	Run uint64
 }
 
 // NewRemoteElevatorState creates a new instance of  RemoteElevatorState
@@ -33,3 +50,29 @@ func NewRemoteElevatorState(id, numFloors int) *RemoteElevatorState {
 		NumFloors:    numFloors,
 	}
 }
+
+// NewRemoteElevatorStateFromLocal builds the shared view of the local elevator
+func NewRemoteElevatorStateFromLocal(id, numFloors int, e *elevator.ElevState) *RemoteElevatorState {
+	res := NewRemoteElevatorState(id, numFloors)
+
+	res.CurrentFloor = e.CurrFloor
+	res.TargetFloor = e.Target.Floor
+	if res.TargetFloor < 0 || res.TargetFloor >= numFloors {
+		res.TargetFloor = max(e.CurrFloor, 0)
+	}
+	res.Direction = e.Dir
+	res.Behavior = e.Behavior
+	res.DoorState = e.Door
+	for f := 0; f < numFloors && f < len(e.Orders); f++ {
+		res.CabCalls[f] = e.Orders[f][elevio.Cab]
+	}
+	res.Independent = e.Mode == elevator.MIndependent
+	res.Full = elevator.IsFull(e)
+
+	return res
+}
+
+// AcceptsHallCalls reports whether the elevator may be assigned hall calls
+func (r *RemoteElevatorState) AcceptsHallCalls() bool {
+	return !r.Independent && !r.Full
+}
@@ -0,0 +1,144 @@
+package statesync
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/Mosazghi/elevator-ttk4145/shared/checksum"
+)
+
+// SnapshotVersion is the version of WorldviewSnapshot and WorldviewDelta
+// this node speaks. Messages of any other version are rejected.
+const SnapshotVersion = 7
+
+// WorldviewSnapshot is a worldview as it is sent to peers. ElevatorStates
+// holds the sender's elevator and every peer it considers alive, by ID.
+// CabBackups holds the cab calls the sender keeps for other elevators, alive
+// or lost. Rev is the sender's revision the snapshot was taken at, and Acks
+// the revision of each peer the sender has merged.
+type WorldviewSnapshot struct {
+	Version        int                    `json:"version"`
+	SenderID       int                    `json:"senderId"`
+	Seq            uint64                 `json:"seq"`
+	NumFloors      int                    `json:"numFloors"`
+	HallCalls      [][2]HallCallPairState `json:"hallCalls"`
+	DestCalls      [][]HallCallPairState  `json:"destCalls"`
+	ElevatorStates []RemoteElevatorState  `json:"elevatorStates"`
+	CabBackups     map[int]CabBackup      `json:"cabBackups,omitempty"`
+	Rev            uint64                 `json:"rev"`
+	Acks           map[int]uint64         `json:"acks,omitempty"`
+	// Checksum covers every other field
+	Checksum uint64 `json:"checksum"`
+}
+
+// Snapshot takes a sealed snapshot of the worldview with the next sequence
+// number, to be sent in full
+func (wv *Worldview) Snapshot() WorldviewSnapshot {
+	wv.mu.Lock()
+	defer wv.mu.Unlock()
+
+	wv.seq++
+	return wv.sealedLocked(wv.seq)
+}
+
+// sealedLocked takes a sealed snapshot with the given sequence number. The
+// caller holds wv.mu.
+func (wv *Worldview) sealedLocked(seq uint64) WorldviewSnapshot {
+	s := wv.snapshotLocked(seq)
+	s.Rev = wv.rev
+	s.Acks = wv.acksLocked()
+	if err := s.seal(); err != nil {
+		// Cannot happen, the snapshot is plain data
+		panic(err)
+	}
+
+	return s
+}
+
+// snapshotLocked copies the worldview's content. The caller holds wv.mu.
+func (wv *Worldview) snapshotLocked(seq uint64) WorldviewSnapshot {
+	s := WorldviewSnapshot{
+		Version:   SnapshotVersion,
+		SenderID:  wv.localID,
+		Seq:       seq,
+		NumFloors: wv.numFloors,
+		HallCalls: slices.Clone(wv.hallCalls),
+		DestCalls: make([][]HallCallPairState, len(wv.destCalls)),
+	}
+
+	for from := range wv.destCalls {
+		s.DestCalls[from] = slices.Clone(wv.destCalls[from])
+	}
+
+	states := []*RemoteElevatorState{wv.localRemoteState}
+	for id, state := range wv.elevatorStates {
+		if id != wv.localID {
+			states = append(states, state)
+		}
+	}
+
+	for _, state := range states {
+		c := *state
+		c.CabCalls = slices.Clone(c.CabCalls)
+		c.SegmentTimes = slices.Clone(c.SegmentTimes)
+		s.ElevatorStates = append(s.ElevatorStates, c)
+	}
+
+	slices.SortFunc(s.ElevatorStates, func(a, b RemoteElevatorState) int {
+		return a.ID - b.ID
+	})
+
+	if len(wv.cabBackups) > 0 {
+		s.CabBackups = make(map[int]CabBackup, len(wv.cabBackups))
+		for id, b := range wv.cabBackups {
+			s.CabBackups[id] = CabBackup{Calls: slices.Clone(b.Calls), Clock: b.Clock, Run: b.Run}
+		}
+	}
+
+	return s
+}
+
+func (s *WorldviewSnapshot) computeChecksum() (uint64, error) {
+	content := *s
+	content.Checksum = 0
+
+	cs, err := checksum.CalculateChecksum(content)
+	if err != nil {
+		return 0, fmt.Errorf("failed to calculate checksum: %w", err)
+	}
+
+	return cs, nil
+}
+
+func (s *WorldviewSnapshot) seal() error {
+	cs, err := s.computeChecksum()
+	if err != nil {
+		return err
+	}
+
+	s.Checksum = cs
+	return nil
+}
+
+func (s *WorldviewSnapshot) verify() error {
+	cs, err := s.computeChecksum()
+	if err != nil {
+		return err
+	}
+
+	if cs != s.Checksum {
+		return fmt.Errorf("data integrity check failed: checksum mismatch")
+	}
+
+	return nil
+}
+
+// senderState returns the sender's own elevator state, or nil if missing
+func (s *WorldviewSnapshot) senderState() *RemoteElevatorState {
+	for i := range s.ElevatorStates {
+		if s.ElevatorStates[i].ID == s.SenderID {
+			return &s.ElevatorStates[i]
+		}
+	}
+	return nil
+}
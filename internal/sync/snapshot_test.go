@@ -0,0 +1,94 @@
+package statesync
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshot_ChecksumCoversContent(t *testing.T) {
+	wv1 := NewWorldView(1, 4)
+	wv2 := NewWorldView(2, 4)
+	require.NoError(t, wv2.SetHallCall(1, HDUp, HSAvailable))
+
+	snapshot := wv2.Snapshot()
+	snapshot.HallCalls[1][HDUp].State = HSProcessing
+
+	err := wv1.MergeSnapshot(&snapshot)
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "checksum mismatch")
+	assert.NotEqual(t, NewWorldView(3, 4).Snapshot().Checksum, wv2.Snapshot().Checksum)
+}
+
+func TestSnapshot_JSONRoundTrip(t *testing.T) {
+	wv1 := NewWorldView(1, 4)
+	wv2 := NewWorldView(2, 4)
+	require.NoError(t, wv2.SetHallCall(3, HDDown, HSAvailable))
+
+	data, err := json.Marshal(wv2.Snapshot())
+	require.NoError(t, err)
+
+	var snapshot WorldviewSnapshot
+	require.NoError(t, json.Unmarshal(data, &snapshot))
+	require.NoError(t, wv1.MergeSnapshot(&snapshot))
+
+	assert.Equal(t, HSAvailable, wv1.hallCalls[3][HDDown].State)
+}
+
+func TestMergeSnapshot_IgnoresStaleSeq(t *testing.T) {
+	wv1 := NewWorldView(1, 4)
+	wv2 := NewWorldView(2, 4)
+
+	old := wv2.Snapshot()
+	require.NoError(t, wv2.SetHallCall(0, HDUp, HSAvailable))
+	newer := wv2.Snapshot()
+
+	require.NoError(t, wv1.MergeSnapshot(&newer))
+	wv1.hallCalls[0][HDUp] = HallCallPairState{}
+	require.NoError(t, wv1.MergeSnapshot(&old))
+	require.NoError(t, wv1.MergeSnapshot(&newer))
+
+	assert.Equal(t, HSNone, wv1.hallCalls[0][HDUp].State, "older and repeated snapshots should be ignored")
+}
+
+func TestMerge_LeavesSenderSeq(t *testing.T) {
+	wv1 := NewWorldView(1, 4)
+	wv2 := NewWorldView(2, 4)
+	wv3 := NewWorldView(3, 4)
+
+	sent := wv2.Snapshot()
+	require.NoError(t, wv1.Merge(wv2))
+	require.NoError(t, wv2.SetHallCall(0, HDUp, HSAvailable))
+	require.NoError(t, wv1.Merge(wv2))
+	next := wv2.Snapshot()
+
+	assert.Equal(t, sent.Seq+1, next.Seq, "merging should not use up the sender's sequence numbers")
+	assert.Equal(t, HSAvailable, wv1.hallCalls[0][HDUp].State, "a direct merge should not be taken for a duplicate")
+
+	// The network still sees every snapshot in order
+	require.NoError(t, wv3.MergeSnapshot(&sent))
+	require.NoError(t, wv3.MergeSnapshot(&next))
+	assert.Equal(t, HSAvailable, wv3.hallCalls[0][HDUp].State)
+}
+
+func TestMergeSnapshot_RejectsBadSnapshots(t *testing.T) {
+	wv1 := NewWorldView(1, 4)
+
+	wrongVersion := NewWorldView(2, 4).Snapshot()
+	wrongVersion.Version = SnapshotVersion + 1
+	require.NoError(t, wrongVersion.seal())
+	assert.Error(t, wv1.MergeSnapshot(&wrongVersion))
+
+	missingSender := NewWorldView(3, 4).Snapshot()
+	missingSender.ElevatorStates = nil
+	require.NoError(t, missingSender.seal())
+	assert.Error(t, wv1.MergeSnapshot(&missingSender))
+
+	own := wv1.Snapshot()
+	assert.Error(t, wv1.MergeSnapshot(&own))
+
+	assert.Error(t, wv1.MergeSnapshot(nil))
+}
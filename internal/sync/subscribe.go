@@ -0,0 +1,69 @@
+package statesync
+
+import (
+	"reflect"
+	"time"
+)
+
+// Subscribe returns a channel receiving a snapshot of the worldview whenever
+// its hall calls, destination calls, cab calls or elevator states change,
+// starting with the current one. Changes made while the reader is busy are
+// coalesced, it only ever gets the latest snapshot, so a slow reader never
+// holds up a merge. The snapshots share nothing with the worldview, but are
+// shared between subscribers and must not be modified.
+func (wv *Worldview) Subscribe() <-chan WorldviewSnapshot {
+	wv.mu.Lock()
+	defer wv.mu.Unlock()
+
+	ch := make(chan WorldviewSnapshot, 1)
+	wv.subscribers = append(wv.subscribers, ch)
+
+	content := wv.snapshotLocked(0)
+	if err := content.seal(); err == nil {
+		ch <- content
+	}
+
+	return ch
+}
+
+// publishLocked hands the snapshot to every subscriber, replacing the one it
+// has not read yet. The caller holds wv.mu, which makes it the only sender.
+func (wv *Worldview) publishLocked(s WorldviewSnapshot) {
+	for _, ch := range wv.subscribers {
+		select {
+		case ch <- s:
+		default:
+			select {
+			case <-ch:
+			default:
+			}
+			ch <- s
+		}
+	}
+}
+
+// ConfirmedHallCalls is GetConfirmedHallCalls as of the snapshot
+func (s *WorldviewSnapshot) ConfirmedHallCalls() [][2]HallCallPairState {
+	alive := make([]int, 0, len(s.ElevatorStates))
+	for _, state := range s.ElevatorStates {
+		alive = append(alive, state.ID)
+	}
+
+	result := make([][2]HallCallPairState, len(s.HallCalls))
+	for floor := range s.HallCalls {
+		for dir, call := range s.HallCalls[floor] {
+			result[floor][dir] = confirmedView(call, alive)
+		}
+	}
+
+	return result
+}
+
+// sameElevatorState reports whether two states of an elevator differ in
+// nothing but their stamps
+func sameElevatorState(a, b *RemoteElevatorState) bool {
+	x, y := *a, *b
+	x.Clock, y.Clock = 0, 0
+	x.LastSeenAt, y.LastSeenAt = time.Time{}, time.Time{}
+	return reflect.DeepEqual(x, y)
+}
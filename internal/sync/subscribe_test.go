@@ -0,0 +1,72 @@
+package statesync
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubscribe_DeliversCurrentThenChanges(t *testing.T) {
+	wv := NewWorldView(1, 4)
+	changes := wv.Subscribe()
+
+	first := <-changes
+	assert.Equal(t, HSNone, first.HallCalls[2][HDUp].State)
+
+	require.NoError(t, wv.SetHallCall(2, HDUp, HSAvailable))
+	assert.Equal(t, HSAvailable, (<-changes).HallCalls[2][HDUp].State)
+
+	wv.SetCabCall(3, true)
+	assert.True(t, (<-changes).ElevatorStates[0].CabCalls[3])
+}
+
+// A reader that falls behind only gets the latest snapshot
+func TestSubscribe_CoalescesBursts(t *testing.T) {
+	wv := NewWorldView(1, 4)
+	changes := wv.Subscribe()
+	<-changes
+
+	for floor := range 4 {
+		require.NoError(t, wv.SetHallCall(floor, HDDown, HSAvailable))
+	}
+
+	latest := <-changes
+	for floor := range 4 {
+		assert.Equal(t, HSAvailable, latest.HallCalls[floor][HDDown].State)
+	}
+	assert.Empty(t, changes)
+}
+
+func TestSubscribe_OnlyOnChange(t *testing.T) {
+	wv1 := NewWorldView(1, 4)
+	wv2 := NewWorldView(2, 4)
+	changes := wv1.Subscribe()
+	<-changes
+
+	local := NewRemoteElevatorState(1, 4)
+	local.CurrentFloor = 2
+	require.NoError(t, wv1.SetLocalElevator(local))
+	<-changes
+
+	again := NewRemoteElevatorState(1, 4)
+	again.CurrentFloor = 2
+	require.NoError(t, wv1.SetLocalElevator(again))
+	assert.Empty(t, changes, "republishing the same state is no change")
+
+	require.NoError(t, wv1.Merge(wv2))
+	<-changes
+	require.NoError(t, wv1.Merge(wv2))
+	assert.Empty(t, changes, "merging the same worldview again is no change")
+}
+
+func TestSnapshot_ConfirmedHallCalls(t *testing.T) {
+	wv1 := NewWorldView(1, 4)
+	wv2 := NewWorldView(2, 4)
+	require.NoError(t, wv1.Merge(wv2))
+	require.NoError(t, wv1.SetHallCall(0, HDUp, HSAvailable))
+
+	s := wv1.Snapshot()
+	assert.Equal(t, HSNone, s.ConfirmedHallCalls()[0][HDUp].State, "node 2 has not seen it")
+	assert.Equal(t, wv1.GetConfirmedHallCalls(), s.ConfirmedHallCalls())
+}
@@ -2,10 +2,13 @@ package statesync
 
 import (
 	"fmt"
+	"maps"
+	"math/rand/v2"
+	"slices"
 	"sync"
 	"time"
 
-	"github.com/Mosazghi/elevator-ttk4145/shared/checksum"
+	network "github.com/Mosazghi/elevator-ttk4145/internal/net"
 )
 
 type Worldview struct {
@@ -13,32 +16,96 @@ type Worldview struct {
 	elevatorStates      map[int]*RemoteElevatorState
 	lostElevatorsState  map[int]*RemoteElevatorState
 	hallCalls           [][2]HallCallPairState
+	destCalls           [][]HallCallPairState // by origin, then destination floor
 	syncLocalRemoteChan chan RemoteElevatorState
 	localRemoteState    *RemoteElevatorState
 	numFloors           int
 	checksum            uint64
-	wvChan              chan Worldview
-	mu                  *sync.Mutex
+	seq                 uint64         // of the last snapshot taken
+	lastSeq             map[int]uint64 // of the last snapshot merged, by sender
+	clock               uint64         // Lamport clock stamping every local change
+	run                 uint64         // picked when the worldview was created, see RemoteElevatorState.Run
+	cabBackups          map[int]CabBackup
+	restoredClock       uint64 // stamp of the newest backup our cab calls were restored from
+	restored            []bool // cab calls restored, not yet handed over on restoreChan
+	restoreChan         chan []bool
+	subscribers         []chan WorldviewSnapshot
+
+	// Revisions for delta sync, see delta.go
+	rev         uint64
+	hallRev     [][2]uint64
+	destRev     [][]uint64
+	localRev    uint64
+	backupRev   map[int]uint64
+	prevContent WorldviewSnapshot
+	prevLocal   RemoteElevatorState
+	peerRev     map[int]uint64 // revision of each peer we have merged
+	ackedBy     map[int]uint64 // revision of ours each peer has merged
+	forceFull   bool           // a peer has asked for a full snapshot
+	lastFull    time.Time
+	resyncs     []int // peers to ask for a full snapshot
+
+	errChan chan error
+	members *Membership
+	joins   []PeerEvent // heard while merging, see unlockAndDispatch
+	mu      *sync.Mutex
 }
 
 // NewWorldView creates a new instance
 func NewWorldView(localID, numFloors int) *Worldview {
 	wv := &Worldview{
-		localID:             localID,
-		elevatorStates:      make(map[int]*RemoteElevatorState),
+		localID:            localID,
+		elevatorStates:     make(map[int]*RemoteElevatorState),
+		lostElevatorsState: make(map[int]*RemoteElevatorState),
+		lastSeq:            make(map[int]uint64),
+		// Counting from the wall clock keeps a restarted node's snapshots and
+		// changes newer than the ones it made before
+		seq:                 uint64(time.Now().UnixNano()),
+		clock:               uint64(time.Now().UnixNano()),
+		rev:                 uint64(time.Now().UnixNano()),
+		hallRev:             make([][2]uint64, numFloors),
+		destRev:             make([][]uint64, numFloors),
+		backupRev:           make(map[int]uint64),
+		peerRev:             make(map[int]uint64),
+		ackedBy:             make(map[int]uint64),
 		hallCalls:           make([][2]HallCallPairState, numFloors),
+		destCalls:           newDestCalls(numFloors),
 		numFloors:           numFloors,
 		syncLocalRemoteChan: make(chan RemoteElevatorState, 10),
-		wvChan:              make(chan Worldview),
+		errChan:             make(chan error, 16),
+		cabBackups:          make(map[int]CabBackup),
+		restoreChan:         make(chan []bool, 1),
+		members:             NewMembership(NodeTimeoutDelay),
 		localRemoteState:    NewRemoteElevatorState(localID, numFloors),
 		mu:                  &sync.Mutex{},
 	}
+	for from := range wv.destRev {
+		wv.destRev[from] = make([]uint64, numFloors)
+	}
+	wv.run = rand.Uint64()
+	wv.localRemoteState.Run = wv.run
+	wv.members.OnEvent(wv.onPeerEvent)
 	wv.updateChecksum()
 	return wv
 }
 
-// StartSyncing creates listeners and transmitters for synchroizations with other elevators
+// StartSyncing creates listeners and transmitters for synchroizations with other elevators.
+// The worldview is broadcast every BroadcastPeriod on the given UDP port, and
+// every worldview received there is merged. Errors are reported on Errors.
 func (wv *Worldview) StartSyncing(port, id int) error {
+	if id != wv.localID {
+		return fmt.Errorf("cannot sync as %d, the worldview belongs to %d", id, wv.localID)
+	}
+
+	txChan, rxChan, netErrChan, err := network.UDPRunNetwork(port)
+	if err != nil {
+		return fmt.Errorf("failed to start network: %w", err)
+	}
+
+	wv.members.Start()
+	go wv.broadcast(txChan)
+	go wv.listen(rxChan, netErrChan)
+
 	return nil
 }
 
@@ -59,7 +126,45 @@ func (wv *Worldview) SetHallCall(floor int, dir HallCallDir, state HallCallState
 
 	wv.hallCalls[floor][dir] = HallCallPairState{
 		State: state,
-		By:    wv.localID,
+		By:    wv.ownerOf(state),
+		Clock: wv.tick(),
+		Acks:  []int{wv.localID},
+	}
+
+	wv.updateChecksum()
+
+	return nil
+}
+
+// ownerOf is who a call we put in the given state belongs to. A call no
+// longer needing an elevator has no owner, which is 0 as elevator IDs start
+// at 1.
+func (wv *Worldview) ownerOf(state HallCallState) int {
+	if state == HSNone {
+		return 0
+	}
+	return wv.localID
+}
+
+// SetDestCall changes the state of the destination call from floor from to
+// floor to
+func (wv *Worldview) SetDestCall(from, to int, state HallCallState) error {
+	wv.mu.Lock()
+	defer wv.mu.Unlock()
+
+	if !IsValidFloor(from, wv.numFloors) || !IsValidFloor(to, wv.numFloors) || from == to {
+		return fmt.Errorf("%v -> %v is not a valid destination call", from, to)
+	}
+
+	if err := IsValidDirTransition(wv.destCalls[from][to].State, state); err != nil {
+		return fmt.Errorf("invalid state transition for destination call %d -> %d: %w", from, to, err)
+	}
+
+	wv.destCalls[from][to] = HallCallPairState{
+		State: state,
+		By:    wv.ownerOf(state),
+		Clock: wv.tick(),
+		Acks:  []int{wv.localID},
 	}
 
 	wv.updateChecksum()
@@ -77,11 +182,150 @@ func (wv *Worldview) SetCabCall(floor int, state bool) bool {
 	}
 
 	wv.localRemoteState.CabCalls[floor] = state
+	wv.localRemoteState.Clock = wv.tick()
 	wv.updateChecksum()
 
 	return true
 }
 
+// ReleaseHallCalls puts every hall call processed by the elevator with the
+// given ID back into the available pool
+func (wv *Worldview) ReleaseHallCalls(id int) {
+	wv.mu.Lock()
+	defer wv.mu.Unlock()
+
+	wv.releaseHallCalls(id)
+	wv.updateChecksum()
+}
+
+func (wv *Worldview) releaseHallCalls(id int) {
+	release := func(call *HallCallPairState) {
+		if call.State == HSProcessing && call.By == id {
+			*call = wv.released(*call)
+		}
+	}
+
+	for floor := range wv.hallCalls {
+		for dir := range wv.hallCalls[floor] {
+			release(&wv.hallCalls[floor][dir])
+		}
+	}
+
+	for from := range wv.destCalls {
+		for to := range wv.destCalls[from] {
+			release(&wv.destCalls[from][to])
+		}
+	}
+}
+
+// released returns the call put back into the available pool by us. The call
+// of a lost elevator is released as of the last we heard from it, so that it
+// serving the call after that, say on the other side of a partition, wins
+// over the release once merged. The alive elevators that acknowledged the
+// call keep their acks, as they know of it already, so that a confirmed call
+// stays confirmed and its lamps stay lit while it waits for a new car. The
+// caller holds wv.mu.
+func (wv *Worldview) released(call HallCallPairState) HallCallPairState {
+	var stamp uint64
+	if state, ok := wv.lostElevatorsState[call.By]; ok {
+		stamp = max(call.Clock, state.Clock)
+	} else {
+		stamp = wv.tick()
+	}
+
+	acks := []int{wv.localID}
+	for _, id := range wv.aliveIDsLocked() {
+		if _, found := slices.BinarySearch(call.Acks, id); found {
+			acks = withAck(acks, id)
+		}
+	}
+
+	return HallCallPairState{State: HSAvailable, By: wv.localID, Clock: stamp, Acks: acks}
+}
+
+// CheckTimeouts expires every elevator not heard from within
+// NodeTimeoutDelay right away, instead of waiting for the membership's timer.
+// It returns the IDs of the elevators lost by this call.
+func (wv *Worldview) CheckTimeouts() []int {
+	return wv.members.Expire(time.Now())
+}
+
+// Members returns the membership tracking which peers are alive. Its
+// handlers are never called with the worldview locked, so they may call back
+// into it.
+func (wv *Worldview) Members() *Membership {
+	return wv.members
+}
+
+// Isolated reports whether no peer has been heard from within
+// NodeTimeoutDelay, as in a network partition. Every call is then confirmed
+// by the local elevator alone, so the node goes on serving on its own.
+func (wv *Worldview) Isolated() bool {
+	return wv.members.Isolated()
+}
+
+// onPeerEvent moves a lost elevator to the lost elevators and releases the
+// hall calls it was processing. Joins are handled by the merge.
+func (wv *Worldview) onPeerEvent(e PeerEvent) {
+	if e.Kind != PeerLost {
+		return
+	}
+
+	wv.mu.Lock()
+	defer wv.mu.Unlock()
+
+	if slices.Contains(wv.members.Alive(), e.ID) {
+		// Heard from again while the loss waited for the lock
+		return
+	}
+
+	state, ok := wv.elevatorStates[e.ID]
+	if !ok {
+		return
+	}
+
+	delete(wv.elevatorStates, e.ID)
+	wv.lostElevatorsState[e.ID] = state
+	wv.releaseHallCalls(e.ID)
+	wv.updateChecksum()
+}
+
+// heard records a heartbeat of the sender of a message being merged. It is
+// recorded before the sender's state is taken, so that the sender cannot be
+// lost in between. A join is held back until unlockAndDispatch, as the
+// handlers may call back into the worldview. The caller holds wv.mu.
+func (wv *Worldview) heard(id int) {
+	if e, joined := wv.members.beat(id, time.Now()); joined {
+		wv.joins = append(wv.joins, e)
+	}
+}
+
+// unlockAndDispatch releases wv.mu and then reports the joins heard while it
+// was held
+func (wv *Worldview) unlockAndDispatch() {
+	joins := wv.joins
+	wv.joins = nil
+	wv.mu.Unlock()
+
+	for _, e := range joins {
+		wv.members.dispatch(e)
+	}
+}
+
+// GetLostElevatorIDs returns the IDs of the elevators that have timed out
+func (wv *Worldview) GetLostElevatorIDs() []int {
+	wv.mu.Lock()
+	defer wv.mu.Unlock()
+
+	ids := make([]int, 0, len(wv.lostElevatorsState))
+	for id := range wv.lostElevatorsState {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+
+	return ids
+}
+
 func (wv *Worldview) SetLocalElevator(elev *RemoteElevatorState) error {
 	wv.mu.Lock()
 	defer wv.mu.Unlock()
@@ -89,7 +333,17 @@ func (wv *Worldview) SetLocalElevator(elev *RemoteElevatorState) error {
 		return err
 	}
 
+	elev.Run = wv.run
+	if sameElevatorState(elev, wv.localRemoteState) {
+		// Nothing new to tell the peers
+		elev.Clock = wv.localRemoteState.Clock
+		wv.localRemoteState = elev
+		return nil
+	}
+
+	elev.Clock = wv.tick()
 	wv.localRemoteState = elev
+	wv.updateChecksum()
 	return nil
 }
 
@@ -109,97 +363,332 @@ func (wv *Worldview) GetAllHallCalls() [][2]HallCallPairState {
 	return result
 }
 
-// Merge merges incoming Worldview into the current one
-func (wv *Worldview) Merge(other *Worldview) error {
+// aliveIDsLocked returns the local elevator and every alive remote elevator.
+// The caller holds wv.mu.
+func (wv *Worldview) aliveIDsLocked() []int {
+	ids := []int{wv.localID}
+	for id := range wv.elevatorStates {
+		if id != wv.localID {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// GetConfirmedHallCalls returns the hall calls with every available call not
+// yet acknowledged by all alive elevators shown as HSNone. Only confirmed
+// calls may be acted on, so that a call is not lost with the node that took
+// it in.
+func (wv *Worldview) GetConfirmedHallCalls() [][2]HallCallPairState {
+	wv.mu.Lock()
+	defer wv.mu.Unlock()
+
+	alive := wv.aliveIDsLocked()
+	result := make([][2]HallCallPairState, len(wv.hallCalls))
+	for floor := range wv.hallCalls {
+		for dir, call := range wv.hallCalls[floor] {
+			result[floor][dir] = confirmedView(call, alive)
+		}
+	}
+
+	return result
+}
+
+// GetConfirmedDestCalls is GetConfirmedHallCalls for destination calls
+func (wv *Worldview) GetConfirmedDestCalls() [][]HallCallPairState {
+	wv.mu.Lock()
+	defer wv.mu.Unlock()
+
+	alive := wv.aliveIDsLocked()
+	result := make([][]HallCallPairState, len(wv.destCalls))
+	for from := range wv.destCalls {
+		result[from] = make([]HallCallPairState, len(wv.destCalls[from]))
+		for to, call := range wv.destCalls[from] {
+			result[from][to] = confirmedView(call, alive)
+		}
+	}
+
+	return result
+}
+
+func confirmedView(call HallCallPairState, alive []int) HallCallPairState {
+	if call.State == HSAvailable && !call.IsConfirmed(alive) {
+		return HallCallPairState{}
+	}
+	return call
+}
+
+// GetAllDestCalls returns the destination calls by origin, then destination
+// floor
+func (wv *Worldview) GetAllDestCalls() [][]HallCallPairState {
 	wv.mu.Lock()
 	defer wv.mu.Unlock()
 
+	result := make([][]HallCallPairState, len(wv.destCalls))
+	for from := range wv.destCalls {
+		result[from] = slices.Clone(wv.destCalls[from])
+	}
+
+	return result
+}
+
+// GetAllElevatorStates returns the local elevator and every alive remote
+// elevator, sorted by ID
+func (wv *Worldview) GetAllElevatorStates() []RemoteElevatorState {
+	wv.mu.Lock()
+	defer wv.mu.Unlock()
+
+	result := []RemoteElevatorState{*wv.localRemoteState}
+	for id, state := range wv.elevatorStates {
+		if id != wv.localID {
+			result = append(result, *state)
+		}
+	}
+
+	slices.SortFunc(result, func(a, b RemoteElevatorState) int {
+		return a.ID - b.ID
+	})
+
+	return result
+}
+
+// Merge merges incoming Worldview into the current one. It is MergeSnapshot
+// for a worldview in the same process, checked against its stored checksum.
+func (wv *Worldview) Merge(other *Worldview) error {
 	if other == nil {
 		return fmt.Errorf("cannot merge with nil worldview")
 	}
 
-	otherHCLen := len(other.hallCalls)
-	ourHCLen := len(wv.hallCalls)
+	if other == wv {
+		return fmt.Errorf("cannot merge worldview with itself")
+	}
+
+	// Leave the other's sequence number be, it belongs to what it sends
+	other.mu.Lock()
+	content := other.snapshotLocked(0)
+	stored := other.checksum
+	snapshot := other.sealedLocked(other.seq)
+	other.mu.Unlock()
 
-	if otherHCLen > ourHCLen || otherHCLen < ourHCLen {
-		return fmt.Errorf("length of hall calls doesnt match")
+	if err := content.seal(); err != nil {
+		return err
+	}
+
+	if content.Checksum != stored {
+		return fmt.Errorf("data integrity check failed: checksum mismatch")
 	}
 
-	if other.numFloors != wv.numFloors {
+	wv.mu.Lock()
+	defer wv.unlockAndDispatch()
+
+	// Taken straight from the other worldview, so it cannot be out of date,
+	// even if a snapshot with the same number was merged before
+	return wv.mergeSnapshot(&snapshot, false)
+}
+
+// MergeSnapshot merges a peer's snapshot into the current worldview. Only the
+// sender's own elevator state is taken from it, unless ours is newer. Calls
+// are joined by their Lamport stamps, so the result does not depend on the
+// order snapshots arrive in. Snapshots older than the last one merged from
+// the same sender are ignored. A snapshot that is invalid, or would leave the
+// worldview invalid, is rejected without any of it being taken in.
+func (wv *Worldview) MergeSnapshot(other *WorldviewSnapshot) error {
+	wv.mu.Lock()
+	defer wv.unlockAndDispatch()
+
+	return wv.mergeSnapshot(other, true)
+}
+
+// mergeSnapshot does the work of MergeSnapshot. Unless ordered, the sequence
+// number is not checked against the last one merged. The caller holds wv.mu.
+func (wv *Worldview) mergeSnapshot(other *WorldviewSnapshot, ordered bool) error {
+	if other == nil {
+		return fmt.Errorf("cannot merge with nil snapshot")
+	}
+
+	if other.Version != SnapshotVersion {
+		return fmt.Errorf("snapshot version %d is not supported, expected %d", other.Version, SnapshotVersion)
+	}
+
+	if other.SenderID == wv.localID {
+		return fmt.Errorf("cannot merge own snapshot")
+	}
+
+	if err := other.verify(); err != nil {
+		return err
+	}
+
+	if other.NumFloors != wv.numFloors {
 		return fmt.Errorf("number of floors doesnt match")
 	}
 
-	calculatedChecksum, err := checksum.CalculateChecksum(other)
-	if err != nil {
-		return fmt.Errorf("failed to calculate checksum: %w", err)
+	if len(other.HallCalls) != len(wv.hallCalls) {
+		return fmt.Errorf("length of hall calls doesnt match")
 	}
 
-	if calculatedChecksum != other.checksum {
-		return fmt.Errorf("data integrity check failed: checksum mismatch")
+	if len(other.DestCalls) != len(wv.destCalls) {
+		return fmt.Errorf("length of destination calls doesnt match")
+	}
+
+	for from := range other.DestCalls {
+		if len(other.DestCalls[from]) != len(wv.destCalls[from]) {
+			return fmt.Errorf("length of destination calls from floor %d doesnt match", from)
+		}
+	}
+
+	if err := validateCabBackups(other.CabBackups, other.NumFloors); err != nil {
+		return err
+	}
+
+	if ordered && other.Seq <= wv.lastSeq[other.SenderID] {
+		// Reordered or duplicated on the way
+		return nil
+	}
+
+	sender := other.senderState()
+	if sender == nil {
+		return fmt.Errorf("snapshot from %d is missing its own elevator state", other.SenderID)
 	}
 
 	// -- Validate Elevator State --
-	if err = ValidateStateRemote(other.localRemoteState); err != nil {
-		return fmt.Errorf("%v's local state is invalid: %w", other.localID, err)
+	if err := ValidateStateRemote(sender); err != nil {
+		return fmt.Errorf("%v's local state is invalid: %w", other.SenderID, err)
 	}
 
-	wv.elevatorStates[other.localID] = other.localRemoteState
+	// -- Validate Hall Calls --
+	if err := validateCalls(other.HallCalls, other.DestCalls); err != nil {
+		return fmt.Errorf("invalid calls from %d: %w", other.SenderID, err)
+	}
 
-	// NOTE!: Approp. place to clean up old elevator states?
-	for id, state := range wv.elevatorStates {
-		if time.Since(state.LastSeenAt) > NodeTimeoutDelay {
-			delete(wv.elevatorStates, id)
+	if ordered {
+		wv.lastSeq[other.SenderID] = other.Seq
+	}
+	wv.heard(other.SenderID)
+
+	next := wv.staged()
+	next.observe(other)
+	next.takeSenderState(other.SenderID, sender)
+	// Before the backups, which are kept until the sender has merged them
+	next.trackPeer(other.SenderID, other.Rev, other.Acks)
+	next.mergeCabBackups(other.CabBackups, sender)
+
+	for floor := range other.HallCalls {
+		for dir := range other.HallCalls[floor] {
+			next.mergeCall(&next.hallCalls[floor][dir], other.HallCalls[floor][dir])
 		}
 	}
 
-	// -- Validate Hall Calls --
-	// Merge hall calls
-	for floor := range other.hallCalls {
-		for dir := range other.hallCalls[floor] {
-			otherDirState := other.hallCalls[floor][dir]
-			ourDirState := wv.hallCalls[floor][dir]
-
-			// 1. Check if others has fullfilled the call
-			if otherDirState.State == HSNone && ourDirState.State == HSProcessing && other.localID == otherDirState.By {
-				wv.hallCalls[floor][dir] = otherDirState
-			}
-
-			// 2. Check if others has received a new order
-			if otherDirState.State == HSAvailable {
-				if ourDirState.State == HSNone {
-					wv.hallCalls[floor][dir] = otherDirState
-				}
-			}
-
-			// 3. Check if others is processing an order
-			// though, it can only go from available -> processing
-			if otherDirState.State == HSProcessing && ourDirState.State == HSAvailable {
-				wv.hallCalls[floor][dir] = otherDirState
-			}
-
-			// NOTE!: For later if we need to release the order [Release active stale orders taken by disconnected elevators]
-			// if otherDirState.State == HSProcessing && ourDirState.State == HSProcessing {
-			// 	if _, exists := wv.elevatorStates[otherDirState.By]; !exists {
-			// 		wv.hallCalls[floor][dir] = HallCallPairState{
-			// 			State: HSAvailable,
-			// 			By:    0,
-			// 		}
-			// 	}
-			// }
+	for from := range other.DestCalls {
+		for to := range other.DestCalls[from] {
+			next.mergeCall(&next.destCalls[from][to], other.DestCalls[from][to])
 		}
 	}
 
+	if err := next.validateLocked(anyElevator); err != nil {
+		return fmt.Errorf("worldview invalid after merging %d: %w", other.SenderID, err)
+	}
+
+	wv.commit(next)
 	return nil
 }
 
-// updateChecksum recalculates the worldview's checksum
+// staged returns a copy of the worldview to merge a message into, so that the
+// result can be checked before it is taken in, see commit. The merge replaces
+// calls, states and backups rather than changing them in place, so copying
+// the containers will do. The caller holds wv.mu.
+func (wv *Worldview) staged() *Worldview {
+	next := *wv
+	next.hallCalls = slices.Clone(wv.hallCalls)
+	next.destCalls = make([][]HallCallPairState, len(wv.destCalls))
+	for from := range wv.destCalls {
+		next.destCalls[from] = slices.Clone(wv.destCalls[from])
+	}
+	next.elevatorStates = maps.Clone(wv.elevatorStates)
+	next.lostElevatorsState = maps.Clone(wv.lostElevatorsState)
+	next.cabBackups = maps.Clone(wv.cabBackups)
+	next.peerRev = maps.Clone(wv.peerRev)
+	next.ackedBy = maps.Clone(wv.ackedBy)
+	return &next
+}
+
+// commit takes in the merge staged in next, publishes it and hands over the
+// cab calls it restored. The caller holds wv.mu.
+func (wv *Worldview) commit(next *Worldview) {
+	*wv = *next
+	wv.updateChecksum()
+	wv.handOverRestored()
+}
+
+// takeSenderState records that the sender is alive, with its elevator state
+// if the message carried a newer one. The caller holds wv.mu.
+func (wv *Worldview) takeSenderState(id int, sender *RemoteElevatorState) {
+	// The sender is alive by our clock, whatever its own clock says
+	known, ok := wv.elevatorStates[id]
+	if !ok {
+		known, ok = wv.lostElevatorsState[id]
+	}
+
+	switch {
+	case sender != nil && (!ok || known.Clock <= sender.Clock):
+		senderState := *sender
+		senderState.CabCalls = slices.Clone(senderState.CabCalls)
+		senderState.SegmentTimes = slices.Clone(senderState.SegmentTimes)
+		senderState.LastSeenAt = time.Now()
+		wv.elevatorStates[id] = &senderState
+	case ok:
+		known.LastSeenAt = time.Now()
+		wv.elevatorStates[id] = known
+	}
+
+	if _, wasLost := wv.lostElevatorsState[id]; wasLost {
+		// Its hall calls were released when it was lost, so it comes back
+		// without any
+		delete(wv.lostElevatorsState, id)
+	}
+}
+
+// mergeCall joins a peer's view of a hall or destination call into ours. The
+// view that supersedes the other wins, and is acknowledged by us. Acks for the
+// change we are both at are pooled.
+func (wv *Worldview) mergeCall(ours *HallCallPairState, other HallCallPairState) {
+	switch {
+	case other.supersedes(*ours):
+		if _, byLost := wv.lostElevatorsState[other.By]; other.State == HSProcessing && byLost {
+			// A peer that has not noticed the loss yet may credit the lost
+			// elevator with a call it took before we heard. Release it
+			// again.
+			*ours = wv.released(other)
+			return
+		}
+		*ours = other
+		ours.Acks = withAck(other.Acks, wv.localID)
+	case sameCallState(*ours, other):
+		ours.Acks = withAck(unionAcks(ours.Acks, other.Acks), wv.localID)
+	}
+}
+
+func newDestCalls(numFloors int) [][]HallCallPairState {
+	calls := make([][]HallCallPairState, numFloors)
+	for from := range calls {
+		calls[from] = make([]HallCallPairState, numFloors)
+	}
+	return calls
+}
+
+// updateChecksum recalculates the worldview's checksum over its content, as
+// a snapshot without a sequence number would carry it, and publishes the
+// content to the subscribers if it has changed
 func (wv *Worldview) updateChecksum() error {
-	cs, err := checksum.CalculateChecksum(wv)
-	if err != nil {
+	content := wv.snapshotLocked(0)
+	if err := content.seal(); err != nil {
 		return err
 	}
 
-	wv.checksum = cs
+	if content.Checksum != wv.checksum {
+		wv.checksum = content.Checksum
+		wv.recordChanges(&content)
+		wv.publishLocked(content)
+	}
 
 	return nil
 }
@@ -159,45 +159,54 @@ func TestMerge_FloorTransitions_ShouldSucceed(t *testing.T) {
 	assert.True(t, wv1.elevatorStates[wv2ID].CabCalls[3], "cab call for floor 3 should be set")
 }
 
-// Test Hall call state machine transitions
+// Test Hall call state machine transitions. The later change wins, whatever
+// order the views arrive in.
 func TestMerge_HallCallStateTransitions(t *testing.T) {
 	tests := []struct {
 		name          string
-		ourState      HallCallState
-		theirState    HallCallState
+		ours          HallCallPairState
+		theirs        HallCallPairState
 		expectedState HallCallState
-		shouldChange  bool
 	}{
-		// Valid transitions
-		{"None -> Available", HSNone, HSAvailable, HSAvailable, true},
-		{"Available -> Processing", HSAvailable, HSProcessing, HSProcessing, true},
-		{"Processing -> None (completed)", HSProcessing, HSNone, HSNone, true},
-
-		// Invalid/ignored transitions
-		{"Available -> Available (duplicate)", HSAvailable, HSAvailable, HSAvailable, false},
-		{"Processing -> Available (cannot go back)", HSProcessing, HSAvailable, HSProcessing, false},
-		{"None -> Processing (skip Available)", HSNone, HSProcessing, HSNone, false},
+		// Later changes win
+		{"None -> Available", HallCallPairState{State: HSNone, Clock: 1}, HallCallPairState{State: HSAvailable, By: 2, Clock: 2}, HSAvailable},
+		{"Available -> Processing", HallCallPairState{State: HSAvailable, By: 1, Clock: 2}, HallCallPairState{State: HSProcessing, By: 2, Clock: 3}, HSProcessing},
+		{"Processing -> None (completed)", HallCallPairState{State: HSProcessing, By: 2, Clock: 3}, HallCallPairState{State: HSNone, Clock: 4}, HSNone},
+		{"None -> Available (called again)", HallCallPairState{State: HSNone, Clock: 4}, HallCallPairState{State: HSAvailable, By: 2, Clock: 5}, HSAvailable},
+		{"None -> Processing (missed Available)", HallCallPairState{State: HSNone, Clock: 1}, HallCallPairState{State: HSProcessing, By: 2, Clock: 3}, HSProcessing},
+
+		// Stale views are ignored
+		{"Available -> Available (duplicate)", HallCallPairState{State: HSAvailable, By: 2, Clock: 2}, HallCallPairState{State: HSAvailable, By: 2, Clock: 2}, HSAvailable},
+		{"Processing -> Available (delayed)", HallCallPairState{State: HSProcessing, By: 2, Clock: 3}, HallCallPairState{State: HSAvailable, By: 1, Clock: 2}, HSProcessing},
+		{"None -> Processing (delayed)", HallCallPairState{State: HSNone, Clock: 4}, HallCallPairState{State: HSProcessing, By: 2, Clock: 3}, HSNone},
+		{"None -> Available (delayed)", HallCallPairState{State: HSNone, Clock: 4}, HallCallPairState{State: HSAvailable, By: 1, Clock: 2}, HSNone},
+
+		// Concurrent changes keep the call alive
+		{"None vs Available", HallCallPairState{State: HSNone, Clock: 4}, HallCallPairState{State: HSAvailable, By: 1, Clock: 4}, HSAvailable},
+		{"None vs Processing", HallCallPairState{State: HSNone, Clock: 4}, HallCallPairState{State: HSProcessing, By: 1, Clock: 4}, HSProcessing},
+		{"Processing vs Available", HallCallPairState{State: HSProcessing, By: 2, Clock: 4}, HallCallPairState{State: HSAvailable, By: 3, Clock: 4}, HSProcessing},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			wv1 := NewWorldView(1, 4)
-			wv2 := NewWorldView(2, 4)
+			for _, swapped := range []bool{false, true} {
+				ours, theirs := tt.ours, tt.theirs
+				if swapped {
+					ours, theirs = theirs, ours
+				}
 
-			wv1.hallCalls[1][HDUp] = HallCallPairState{State: tt.ourState, By: 1}
+				wv1 := NewWorldView(1, 4)
+				wv2 := NewWorldView(2, 4)
 
-			wv2.hallCalls[1][HDUp] = HallCallPairState{State: tt.theirState, By: 2}
+				wv1.hallCalls[1][HDUp] = ours
+				wv2.hallCalls[1][HDUp] = theirs
+				require.NoError(t, wv2.updateChecksum())
 
-			require.NoError(t, wv2.updateChecksum())
+				require.NoError(t, wv1.Merge(wv2))
 
-			// Merge
-			err := wv1.Merge(wv2)
-			require.NoError(t, err)
-
-			// Verify transition
-			assert.Equal(t, tt.expectedState, wv1.hallCalls[1][HDUp].State,
-				"state transition %v -> %v should result in %v",
-				tt.ourState, tt.theirState, tt.expectedState)
+				assert.Equal(t, tt.expectedState, wv1.hallCalls[1][HDUp].State,
+					"merging %v into %v should result in %v", theirs, ours, tt.expectedState)
+			}
 		})
 	}
 }
@@ -244,6 +253,13 @@ func TestSetCabCall(t *testing.T) {
 	assert.False(t, success, "should not be able to set cab call for invalid floor")
 }
 
+func TestIsValidFloor(t *testing.T) {
+	assert.True(t, IsValidFloor(0, 4))
+	assert.True(t, IsValidFloor(3, 4))
+	assert.False(t, IsValidFloor(4, 4), "floors count from 0")
+	assert.False(t, IsValidFloor(-1, 4))
+}
+
 func TestSetLocalElevator(t *testing.T) {
 	wv := NewWorldView(1, 4)
 
@@ -268,3 +284,155 @@ func TestSetLocalElevator(t *testing.T) {
 
 	assert.Error(t, err, "should not be able to set invalid local elevator state")
 }
+
+func TestRemoteStateFromLocal_Independent(t *testing.T) {
+	local := elevator.NewElevState(2, [4][3]bool{}, nil)
+	local.Orders[3][elevio.Cab] = true
+
+	res := NewRemoteElevatorStateFromLocal(1, 4, local)
+
+	require.NoError(t, ValidateStateRemote(res))
+	assert.True(t, res.AcceptsHallCalls(), "normal mode should accept hall calls")
+	assert.True(t, res.CabCalls[3], "cab calls should be copied")
+	assert.Equal(t, 2, res.TargetFloor, "unset target should fall back to current floor")
+
+	local.Mode = elevator.MIndependent
+	local.Behavior = elevator.BDoorOpen
+	local.Door = elevator.DSOpen
+
+	res = NewRemoteElevatorStateFromLocal(1, 4, local)
+
+	require.NoError(t, ValidateStateRemote(res))
+	assert.True(t, res.Independent)
+	assert.False(t, res.AcceptsHallCalls(), "independent car should not accept hall calls")
+	assert.Equal(t, elevator.DSOpen, res.DoorState)
+}
+
+func TestRemoteStateFromLocal_FullLoad(t *testing.T) {
+	drv := elevio.NewLoadSimDriver(nil, 10)
+	local := elevator.NewElevState(0, [4][3]bool{}, drv)
+
+	drv.Board(7)
+	res := NewRemoteElevatorStateFromLocal(1, 4, local)
+	assert.False(t, res.Full, "car below threshold should not be full")
+	assert.True(t, res.AcceptsHallCalls())
+
+	drv.Board(5)
+	res = NewRemoteElevatorStateFromLocal(1, 4, local)
+	assert.True(t, res.Full, "car at capacity should be full")
+	assert.False(t, res.AcceptsHallCalls(), "full car should not accept hall calls")
+
+	drv.Alight(4)
+	res = NewRemoteElevatorStateFromLocal(1, 4, local)
+	assert.False(t, res.Full)
+}
+
+// An elevator that times out is moved to the lost elevators and its
+// processing hall calls go back to the available pool
+func TestCheckTimeouts_ReleasesLostElevatorsCalls(t *testing.T) {
+	wv := NewWorldView(1, 4)
+	wv.elevatorStates[2] = NewRemoteElevatorState(2, 4)
+	wv.elevatorStates[2].Clock = 7
+	wv.elevatorStates[3] = NewRemoteElevatorState(3, 4)
+	wv.members.Heartbeat(2, time.Now().Add(-2*NodeTimeoutDelay))
+	wv.members.Heartbeat(3, time.Now())
+
+	wv.hallCalls[1][HDUp] = HallCallPairState{State: HSProcessing, By: 2, Clock: 4}
+	wv.hallCalls[2][HDDown] = HallCallPairState{State: HSProcessing, By: 3}
+
+	lost := wv.CheckTimeouts()
+
+	assert.Equal(t, []int{2}, lost)
+	assert.NotContains(t, wv.elevatorStates, 2)
+	assert.Contains(t, wv.lostElevatorsState, 2)
+	assert.Contains(t, wv.elevatorStates, 3, "alive elevator should stay")
+	released := wv.hallCalls[1][HDUp]
+	assert.Equal(t, HSAvailable, released.State, "lost elevator's call should be released")
+	assert.Equal(t, []int{1}, released.Acks)
+	assert.Equal(t, uint64(7), released.Clock, "release should be stamped as of the last heard from the elevator")
+	assert.Equal(t, HallCallPairState{State: HSProcessing, By: 3}, wv.hallCalls[2][HDDown], "alive elevator's call should be kept")
+	assert.Equal(t, []int{2}, wv.GetLostElevatorIDs())
+
+	assert.Empty(t, wv.CheckTimeouts(), "already lost elevator should not be reported again")
+}
+
+// A lost elevator that is heard from again is restored
+func TestMerge_RestoresLostElevator(t *testing.T) {
+	wv1 := NewWorldView(1, 4)
+	wv1.lostElevatorsState[2] = NewRemoteElevatorState(2, 4)
+
+	wv2 := NewWorldView(2, 4)
+	// Stale clock on the sender should not matter
+	wv2.localRemoteState.LastSeenAt = time.Now().Add(-time.Hour)
+	require.NoError(t, wv2.updateChecksum())
+
+	require.NoError(t, wv1.Merge(wv2))
+
+	assert.Contains(t, wv1.elevatorStates, 2)
+	assert.NotContains(t, wv1.lostElevatorsState, 2)
+	assert.Empty(t, wv1.CheckTimeouts(), "restored elevator should count as seen now")
+}
+
+// A peer that has not noticed the loss yet cannot hand the call back to the
+// lost elevator, even if the lost elevator took it after we released it. The
+// peer keeps the claim until it loses the elevator too.
+func TestMerge_IgnoresProcessingByLostElevator(t *testing.T) {
+	wv1 := NewWorldView(1, 4)
+	wv1.lostElevatorsState[3] = NewRemoteElevatorState(3, 4)
+	wv1.hallCalls[2][HDUp] = HallCallPairState{State: HSAvailable, By: 1, Clock: 5}
+
+	wv2 := NewWorldView(2, 4)
+	wv2.elevatorStates[3] = NewRemoteElevatorState(3, 4)
+	wv2.hallCalls[2][HDUp] = HallCallPairState{State: HSProcessing, By: 3, Clock: wv1.clock + 10}
+	require.NoError(t, wv2.updateChecksum())
+
+	require.NoError(t, wv1.Merge(wv2))
+	assert.Equal(t, HallCallPairState{State: HSAvailable, By: 1, Clock: wv1.clock, Acks: []int{1}}, wv1.hallCalls[2][HDUp])
+
+	require.NoError(t, wv2.Merge(wv1))
+	assert.Equal(t, HSProcessing, wv2.hallCalls[2][HDUp].State, "peer still hearing from 3 should keep its claim")
+
+	wv2.onPeerEvent(PeerEvent{ID: 3, Kind: PeerLost})
+	require.NoError(t, wv1.Merge(wv2))
+	require.NoError(t, wv2.Merge(wv1))
+	assert.Equal(t, HSAvailable, wv2.hallCalls[2][HDUp].State)
+	assert.Equal(t, wv1.hallCalls[2][HDUp], wv2.hallCalls[2][HDUp], "both should agree on the release")
+}
+
+func TestSetDestCall(t *testing.T) {
+	wv := NewWorldView(1, 4)
+
+	require.NoError(t, wv.SetDestCall(0, 3, HSAvailable))
+	require.NoError(t, wv.SetDestCall(0, 3, HSProcessing))
+
+	assert.Equal(t, HallCallPairState{State: HSProcessing, By: 1, Clock: wv.clock, Acks: []int{1}}, wv.GetAllDestCalls()[0][3])
+	assert.Equal(t, HSNone, wv.GetAllDestCalls()[3][0].State, "opposite journey is a different call")
+
+	assert.Error(t, wv.SetDestCall(2, 2, HSAvailable), "destination must differ from origin")
+	assert.Error(t, wv.SetDestCall(0, 3, HSAvailable), "should not be able to transition from Processing to Available")
+}
+
+// Destination calls follow the same merge rules as hall calls
+func TestMerge_DestCalls(t *testing.T) {
+	wv1 := NewWorldView(1, 4)
+	wv1.destCalls[2][0] = HallCallPairState{State: HSProcessing, By: 2, Clock: 1}
+
+	wv2 := NewWorldView(2, 4)
+	wv2.destCalls[1][3] = HallCallPairState{State: HSAvailable, By: 2, Clock: 1}
+	wv2.destCalls[2][0] = HallCallPairState{State: HSNone, Clock: 2}
+	require.NoError(t, wv2.updateChecksum())
+
+	require.NoError(t, wv1.Merge(wv2))
+
+	assert.Equal(t, HSAvailable, wv1.destCalls[1][3].State, "new call should be merged")
+	assert.Equal(t, HSNone, wv1.destCalls[2][0].State, "call served by the sender should be cleared")
+}
+
+func TestReleaseHallCalls_ReleasesDestCalls(t *testing.T) {
+	wv := NewWorldView(1, 4)
+	wv.destCalls[0][2] = HallCallPairState{State: HSProcessing, By: 2}
+
+	wv.ReleaseHallCalls(2)
+
+	assert.Equal(t, HallCallPairState{State: HSAvailable, By: 1, Clock: wv.clock, Acks: []int{1}}, wv.destCalls[0][2])
+}
@@ -0,0 +1,163 @@
+package statesync
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	network "github.com/Mosazghi/elevator-ttk4145/internal/net"
+)
+
+// message is what goes over the wire, one of a full snapshot, a delta or a
+// request for a full snapshot
+type message struct {
+	Full   *WorldviewSnapshot `json:"full,omitempty"`
+	Delta  *WorldviewDelta    `json:"delta,omitempty"`
+	Resync *resyncRequest     `json:"resync,omitempty"`
+}
+
+// resyncRequest asks Target for a full snapshot
+type resyncRequest struct {
+	SenderID int `json:"senderId"`
+	Target   int `json:"target"`
+}
+
+// encode takes a full snapshot or a delta for broadcasting. A full snapshot
+// is sent every FullSyncPeriod, when asked for, or when an alive peer has
+// not merged anything of ours yet.
+func (wv *Worldview) encode() ([]byte, error) {
+	alive := wv.members.Alive()
+
+	wv.mu.Lock()
+	base := wv.deltaBase(alive)
+	full := wv.forceFull || base == 0 || time.Since(wv.lastFull) >= FullSyncPeriod
+	var msg message
+	if full {
+		wv.forceFull = false
+		wv.lastFull = time.Now()
+	} else {
+		d, err := wv.deltaLocked(base)
+		if err != nil {
+			wv.mu.Unlock()
+			return nil, fmt.Errorf("failed to take delta: %w", err)
+		}
+		msg.Delta = &d
+	}
+	wv.mu.Unlock()
+
+	if full {
+		s := wv.Snapshot()
+		msg.Full = &s
+	}
+
+	return encodeMessage(msg)
+}
+
+// encodeResyncs asks every peer we missed a change from for a full snapshot
+func (wv *Worldview) encodeResyncs() ([][]byte, error) {
+	var result [][]byte
+	for _, id := range wv.takeResyncs() {
+		data, err := encodeMessage(message{Resync: &resyncRequest{SenderID: wv.localID, Target: id}})
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, data)
+	}
+	return result, nil
+}
+
+func encodeMessage(msg message) ([]byte, error) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode worldview: %w", err)
+	}
+
+	if len(data) > network.MaxDatagramSize {
+		return nil, fmt.Errorf("worldview is %d bytes, more than a datagram holds", len(data))
+	}
+
+	return data, nil
+}
+
+// receive merges a broadcast snapshot or delta, or answers a request for a
+// full snapshot. Our own broadcasts are ignored.
+func (wv *Worldview) receive(data []byte) error {
+	var msg message
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return fmt.Errorf("failed to decode worldview: %w", err)
+	}
+
+	switch {
+	case msg.Full != nil:
+		if msg.Full.SenderID == wv.localID {
+			return nil
+		}
+		if err := wv.MergeSnapshot(msg.Full); err != nil {
+			return fmt.Errorf("failed to merge worldview from %d: %w", msg.Full.SenderID, err)
+		}
+	case msg.Delta != nil:
+		if msg.Delta.SenderID == wv.localID {
+			return nil
+		}
+		if err := wv.MergeDelta(msg.Delta); err != nil {
+			return fmt.Errorf("failed to merge delta from %d: %w", msg.Delta.SenderID, err)
+		}
+	case msg.Resync != nil:
+		if msg.Resync.Target == wv.localID {
+			wv.requestResync()
+		}
+	default:
+		return fmt.Errorf("empty message")
+	}
+
+	return nil
+}
+
+// reportError hands err to whoever reads Errors, dropping it if they have
+// fallen behind rather than stalling the sync
+func (wv *Worldview) reportError(err error) {
+	select {
+	case wv.errChan <- err:
+	default:
+	}
+}
+
+// Errors returns the channel sync errors are reported on
+func (wv *Worldview) Errors() <-chan error {
+	return wv.errChan
+}
+
+func (wv *Worldview) broadcast(txChan chan<- network.UDPMessage) {
+	ticker := time.NewTicker(BroadcastPeriod)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		resyncs, err := wv.encodeResyncs()
+		if err != nil {
+			wv.reportError(err)
+		}
+		for _, data := range resyncs {
+			txChan <- network.UDPMessage{Data: data}
+		}
+
+		data, err := wv.encode()
+		if err != nil {
+			wv.reportError(err)
+			continue
+		}
+		txChan <- network.UDPMessage{Data: data}
+	}
+}
+
+func (wv *Worldview) listen(rxChan <-chan network.UDPMessage, netErrChan <-chan error) {
+	for {
+		select {
+		case msg := <-rxChan:
+			if err := wv.receive(msg.Data); err != nil {
+				wv.reportError(err)
+			}
+		case err := <-netErrChan:
+			wv.reportError(fmt.Errorf("network: %w", err))
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package statesync
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReceive_MergesPeerWorldview(t *testing.T) {
+	wv1 := NewWorldView(1, 4)
+	wv2 := NewWorldView(2, 4)
+	require.NoError(t, wv2.SetHallCall(2, HDDown, HSAvailable))
+	require.NoError(t, wv2.SetDestCall(0, 3, HSAvailable))
+	wv2.SetCabCall(1, true)
+
+	data, err := wv2.encode()
+	require.NoError(t, err)
+	require.NoError(t, wv1.receive(data))
+
+	assert.Equal(t, HallCallPairState{State: HSAvailable, By: 2, Clock: wv2.hallCalls[2][HDDown].Clock, Acks: []int{1, 2}}, wv1.GetAllHallCalls()[2][HDDown])
+	assert.Equal(t, HSAvailable, wv1.GetAllDestCalls()[0][3].State)
+	require.Contains(t, wv1.elevatorStates, 2)
+	assert.True(t, wv1.elevatorStates[2].CabCalls[1], "sender's elevator state should be merged")
+}
+
+func TestReceive_IgnoresOwnBroadcast(t *testing.T) {
+	wv := NewWorldView(1, 4)
+
+	data, err := wv.encode()
+	require.NoError(t, err)
+	require.NoError(t, wv.receive(data))
+
+	assert.NotContains(t, wv.elevatorStates, 1, "own echo should not show up as a peer")
+}
+
+func TestReceive_RejectsGarbage(t *testing.T) {
+	wv := NewWorldView(1, 4)
+
+	assert.Error(t, wv.receive([]byte("Hello from A")))
+	assert.Error(t, wv.receive([]byte(`{"full":{"version":99,"senderId":2}}`)), "unknown version")
+	assert.Error(t, wv.receive([]byte(`{}`)), "empty message")
+}
+
+func TestStartSyncing_WrongID(t *testing.T) {
+	wv := NewWorldView(1, 4)
+
+	assert.Error(t, wv.StartSyncing(30000, 2))
+}
@@ -16,7 +16,25 @@ const (
 	HDDown
 )
 
+func (s HallCallState) String() string {
+	switch s {
+	case HSNone:
+		return "NONE"
+	case HSAvailable:
+		return "AVAILABLE"
+	case HSProcessing:
+		return "PROCESSING"
+	}
+	return "UNKNOWN"
+}
+
+// HallCallPairState is the shared state of one hall call, or of one
+// destination call in destination dispatch mode
 type HallCallPairState struct {
 	State HallCallState
 	By    int
+	// Clock is the Lamport time of the change that put the call in this state
+	Clock uint64
+	// Acks are the elevators known to have seen the call in this state
+	Acks []int `json:",omitempty"`
 }
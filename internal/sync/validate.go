@@ -1,14 +1,173 @@
 package statesync
 
 import (
+	"cmp"
+	"errors"
 	"fmt"
 	"log"
+	"slices"
 
 	"github.com/Mosazghi/elevator-ttk4145/internal/elevator"
 )
 
+// ValidateStateWv checks the invariants of the whole worldview and reports
+// every violation, each prefixed with the path to the offending field
 func ValidateStateWv(wv *Worldview) error {
-	return nil
+	wv.mu.Lock()
+	defer wv.mu.Unlock()
+
+	return wv.validateLocked(wv.knownLocked)
+}
+
+// knownLocked reports whether the elevator is us or one we have heard from.
+// The caller holds wv.mu.
+func (wv *Worldview) knownLocked(id int) bool {
+	_, alive := wv.elevatorStates[id]
+	_, lost := wv.lostElevatorsState[id]
+	return id == wv.localID || alive || lost
+}
+
+// anyElevator accepts every valid elevator ID. After a merge, a call may be
+// processed by an elevator the sender has heard from and we have not yet.
+func anyElevator(id int) bool {
+	return id > 0
+}
+
+// validateLocked is ValidateStateWv, with known deciding which elevators may
+// process a call. The caller holds wv.mu.
+func (wv *Worldview) validateLocked(known func(id int) bool) error {
+	var errs []error
+	report := func(path, format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%s: %s", path, fmt.Sprintf(format, args...)))
+	}
+
+	if len(wv.hallCalls) != wv.numFloors {
+		report("hallCalls", "length %d does not match number of floors %d", len(wv.hallCalls), wv.numFloors)
+	}
+	if len(wv.destCalls) != wv.numFloors {
+		report("destCalls", "length %d does not match number of floors %d", len(wv.destCalls), wv.numFloors)
+	}
+	for from := range wv.destCalls {
+		if len(wv.destCalls[from]) != wv.numFloors {
+			report(fmt.Sprintf("destCalls[%d]", from), "length %d does not match number of floors %d", len(wv.destCalls[from]), wv.numFloors)
+		}
+	}
+	errs = append(errs, callErrors(wv.hallCalls, wv.destCalls, known)...)
+
+	checkState := func(path string, id int, state *RemoteElevatorState) {
+		if state == nil {
+			report(path, "missing")
+			return
+		}
+		if state.ID != id {
+			report(path+".ID", "%d does not match %d", state.ID, id)
+		}
+		if state.NumFloors != wv.numFloors {
+			report(path+".NumFloors", "%d does not match number of floors %d", state.NumFloors, wv.numFloors)
+		}
+		if err := ValidateStateRemote(state); err != nil {
+			report(path, "%v", err)
+		}
+	}
+
+	checkState("localRemoteState", wv.localID, wv.localRemoteState)
+	for id, state := range wv.elevatorStates {
+		path := fmt.Sprintf("elevatorStates[%d]", id)
+		checkState(path, id, state)
+		if id == wv.localID {
+			report(path, "local elevator listed as remote")
+		}
+		if _, lost := wv.lostElevatorsState[id]; lost {
+			report(path, "elevator is both alive and lost")
+		}
+	}
+	for id, state := range wv.lostElevatorsState {
+		path := fmt.Sprintf("lostElevatorsState[%d]", id)
+		checkState(path, id, state)
+		if id == wv.localID {
+			report(path, "local elevator listed as lost")
+		}
+	}
+
+	for id, b := range wv.cabBackups {
+		if len(b.Calls) != wv.numFloors {
+			report(fmt.Sprintf("cabBackups[%d].Calls", id), "length %d does not match number of floors %d", len(b.Calls), wv.numFloors)
+		}
+	}
+
+	// Sorted, so the same worldview always gives the same error
+	slices.SortFunc(errs, func(a, b error) int {
+		return cmp.Compare(a.Error(), b.Error())
+	})
+
+	return errors.Join(errs...)
+}
+
+// callErrors checks every hall and destination call, each error prefixed with
+// the path to the offending field. known decides which elevators may process
+// a call.
+func callErrors(hallCalls [][2]HallCallPairState, destCalls [][]HallCallPairState, known func(id int) bool) []error {
+	var errs []error
+	for floor := range hallCalls {
+		for dir, call := range hallCalls[floor] {
+			errs = append(errs, checkCall(fmt.Sprintf("hallCalls[%d][%d]", floor, dir), call, known)...)
+		}
+	}
+
+	for from := range destCalls {
+		for to, call := range destCalls[from] {
+			errs = append(errs, checkDestCall(fmt.Sprintf("destCalls[%d][%d]", from, to), from, to, call, known)...)
+		}
+	}
+
+	return errs
+}
+
+// checkCall checks a single hall or destination call found at path
+func checkCall(path string, call HallCallPairState, known func(id int) bool) []error {
+	var errs []error
+	report := func(field, format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%s.%s: %s", path, field, fmt.Sprintf(format, args...)))
+	}
+
+	switch call.State {
+	case HSNone:
+		if call.By != 0 {
+			report("By", "call in state %v has owner %d", call.State, call.By)
+		}
+	case HSAvailable:
+	case HSProcessing:
+		if !known(call.By) {
+			report("By", "call is processed by unknown elevator %d", call.By)
+		}
+	default:
+		report("State", "invalid hall call state %d", call.State)
+	}
+
+	if !slices.IsSorted(call.Acks) || len(slices.Compact(slices.Clone(call.Acks))) != len(call.Acks) {
+		report("Acks", "acks %v are not sorted and unique", call.Acks)
+	}
+
+	return errs
+}
+
+// checkDestCall is checkCall for the destination call from one floor to another
+func checkDestCall(path string, from, to int, call HallCallPairState, known func(id int) bool) []error {
+	errs := checkCall(path, call, known)
+	if from == to && call.State != HSNone {
+		errs = append(errs, fmt.Errorf("%s.State: destination call to its own origin is %v", path, call.State))
+	}
+	return errs
+}
+
+// validateCalls checks the calls of a message before they are merged. Any
+// valid elevator may process them, as the sender may know of one we do not.
+func validateCalls(hallCalls [][2]HallCallPairState, destCalls [][]HallCallPairState) error {
+	errs := callErrors(hallCalls, destCalls, anyElevator)
+	slices.SortFunc(errs, func(a, b error) int {
+		return cmp.Compare(a.Error(), b.Error())
+	})
+	return errors.Join(errs...)
 }
 
 // ValidateStateRemote does sanity check on a remote elevator state
@@ -34,5 +193,19 @@ func ValidateStateRemote(res *RemoteElevatorState) error {
 		return fmt.Errorf("cab calls length %d does not match number of floors %d", len(res.CabCalls), res.NumFloors)
 	}
 
+	if len(res.SegmentTimes) != 0 && len(res.SegmentTimes) != res.NumFloors-1 {
+		return fmt.Errorf("segment times length %d does not match number of floors %d", len(res.SegmentTimes), res.NumFloors)
+	}
+
+	for i, d := range res.SegmentTimes {
+		if d < 0 {
+			return fmt.Errorf("segment %d has negative travel time %v", i, d)
+		}
+	}
+
+	if res.DoorTime < 0 {
+		return fmt.Errorf("door time %v is negative", res.DoorTime)
+	}
+
 	return nil
 }
@@ -0,0 +1,141 @@
+package statesync
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// requireValid fails the test if any of the worldviews breaks an invariant
+func requireValid(t *testing.T, wvs ...*Worldview) {
+	t.Helper()
+	for _, wv := range wvs {
+		require.NoError(t, ValidateStateWv(wv), "worldview of %d", wv.localID)
+	}
+}
+
+func TestValidateStateWv_Valid(t *testing.T) {
+	wv1, wv2, wv3 := threeNodes(t)
+	require.NoError(t, wv1.SetHallCall(1, HDUp, HSAvailable))
+	require.NoError(t, wv1.SetHallCall(1, HDUp, HSProcessing))
+	require.NoError(t, wv1.SetHallCall(1, HDUp, HSNone))
+	require.NoError(t, wv2.SetDestCall(0, 3, HSAvailable))
+	wv3.SetCabCall(2, true)
+	require.NoError(t, wv3.SetHallCall(2, HDDown, HSAvailable))
+	require.NoError(t, wv3.SetHallCall(2, HDDown, HSProcessing))
+	require.NoError(t, wv2.Merge(wv3))
+	wv2.Members().Expire(time.Now().Add(2 * NodeTimeoutDelay))
+
+	requireValid(t, wv1, wv2, wv3)
+}
+
+// Every violation is reported, with the path to it
+func TestValidateStateWv_ReportsEveryViolation(t *testing.T) {
+	wv := NewWorldView(1, 4)
+	wv.hallCalls[0][HDUp] = HallCallPairState{State: HSNone, By: 1}
+	wv.hallCalls[2][HDDown] = HallCallPairState{State: HSProcessing, By: 9}
+	wv.destCalls[1][1] = HallCallPairState{State: HSAvailable, By: 1}
+	wv.hallCalls[3][HDUp] = HallCallPairState{State: HSAvailable, Acks: []int{2, 1}}
+	wv.elevatorStates[2] = NewRemoteElevatorState(3, 4)
+	wv.lostElevatorsState[4] = NewRemoteElevatorState(4, 3)
+	wv.cabBackups[5] = CabBackup{Calls: make([]bool, 2)}
+
+	err := ValidateStateWv(wv)
+	require.Error(t, err)
+
+	for _, want := range []string{
+		"hallCalls[0][0].By: call in state NONE has owner 1",
+		"hallCalls[2][1].By: call is processed by unknown elevator 9",
+		"hallCalls[3][0].Acks:",
+		"destCalls[1][1].State:",
+		"elevatorStates[2].ID: 3 does not match 2",
+		"lostElevatorsState[4].NumFloors:",
+		"cabBackups[5].Calls:",
+	} {
+		assert.Contains(t, err.Error(), want)
+	}
+	assert.Len(t, strings.Split(err.Error(), "\n"), 7, "every violation on its own line")
+}
+
+func TestValidateStateWv_HallCallsLength(t *testing.T) {
+	wv := NewWorldView(1, 4)
+	wv.hallCalls = wv.hallCalls[:3]
+
+	assert.ErrorContains(t, ValidateStateWv(wv), "hallCalls: length 3 does not match number of floors 4")
+}
+
+// A merge leaving the worldview broken is reported
+func TestMerge_ReportsInvalidResult(t *testing.T) {
+	wv1 := NewWorldView(1, 4)
+	wv2 := NewWorldView(2, 4)
+	wv2.hallCalls[1][HDUp] = HallCallPairState{State: HSProcessing, Clock: 1}
+	require.NoError(t, wv2.updateChecksum())
+
+	assert.ErrorContains(t, wv1.Merge(wv2), "hallCalls[1][0].By: call is processed by unknown elevator 0")
+}
+
+// A message with an invalid call is rejected before any of it is taken in
+func TestMerge_InvalidCallsAreNotTakenIn(t *testing.T) {
+	wv1 := NewWorldView(1, 4)
+	changes := wv1.Subscribe()
+	<-changes
+	wv2 := NewWorldView(2, 4)
+	require.NoError(t, wv2.SetHallCall(0, HDUp, HSAvailable))
+	wv2.hallCalls[1][HDUp] = HallCallPairState{State: HSProcessing, Clock: 1}
+	require.NoError(t, wv2.updateChecksum())
+
+	assert.ErrorContains(t, wv1.Merge(wv2), "invalid calls from 2")
+
+	assert.Equal(t, HSNone, wv1.hallCalls[0][HDUp].State, "the valid call should not be taken in either")
+	assert.Len(t, wv1.GetAllElevatorStates(), 1)
+	assert.Empty(t, changes, "nothing should be published")
+}
+
+// A merge that would leave the worldview broken is rolled back
+func TestMerge_InvalidResultIsRolledBack(t *testing.T) {
+	wv1 := NewWorldView(1, 4)
+	changes := wv1.Subscribe()
+	<-changes
+	before := wv1.checksum
+	wv2 := NewWorldView(2, 4)
+	require.NoError(t, wv2.SetHallCall(0, HDUp, HSAvailable))
+	// Fine on its own, but not in a building of four floors
+	wv2.localRemoteState = NewRemoteElevatorState(2, 3)
+	require.NoError(t, wv2.updateChecksum())
+
+	assert.ErrorContains(t, wv1.Merge(wv2), "elevatorStates[2].NumFloors: 3 does not match number of floors 4")
+
+	assert.Equal(t, HSNone, wv1.hallCalls[0][HDUp].State)
+	assert.Len(t, wv1.GetAllElevatorStates(), 1)
+	assert.Equal(t, before, wv1.checksum)
+	assert.Empty(t, changes, "nothing should be published")
+	require.NoError(t, ValidateStateWv(wv1))
+}
+
+// A call processed by an elevator only the sender has heard from is merged
+func TestMerge_CallOfUnheardElevator(t *testing.T) {
+	wv1 := NewWorldView(1, 4)
+	wv2 := NewWorldView(2, 4)
+	wv3 := NewWorldView(3, 4)
+	require.NoError(t, wv3.SetHallCall(1, HDUp, HSAvailable))
+	require.NoError(t, wv3.SetHallCall(1, HDUp, HSProcessing))
+	require.NoError(t, wv2.Merge(wv3))
+
+	require.NoError(t, wv1.Merge(wv2))
+	require.NoError(t, wv1.Merge(wv2))
+
+	assert.Equal(t, HallCallPairState{State: HSProcessing, By: 3}, HallCallPairState{State: wv1.hallCalls[1][HDUp].State, By: wv1.hallCalls[1][HDUp].By})
+}
+
+func TestSetHallCall_NoneHasNoOwner(t *testing.T) {
+	wv := NewWorldView(1, 4)
+	require.NoError(t, wv.SetHallCall(2, HDUp, HSAvailable))
+	require.NoError(t, wv.SetHallCall(2, HDUp, HSProcessing))
+	require.NoError(t, wv.SetHallCall(2, HDUp, HSNone))
+
+	assert.Equal(t, 0, wv.hallCalls[2][HDUp].By)
+	requireValid(t, wv)
+}
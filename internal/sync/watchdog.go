@@ -1 +1,234 @@
 package statesync
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+type PeerEventKind int
+
+const (
+	PeerJoined PeerEventKind = iota
+	PeerLost
+)
+
+func (k PeerEventKind) String() string {
+	switch k {
+	case PeerJoined:
+		return "JOINED"
+	case PeerLost:
+		return "LOST"
+	}
+	return "UNKNOWN"
+}
+
+// PeerEvent reports a peer joining or being lost. At is when it was noticed,
+// LastSeen when the peer was last heard from.
+type PeerEvent struct {
+	ID       int
+	Kind     PeerEventKind
+	At       time.Time
+	LastSeen time.Time
+}
+
+func (e PeerEvent) String() string {
+	return fmt.Sprintf("elevator %d %v at %s", e.ID, e.Kind, e.At.Format("15:04:05.000"))
+}
+
+// peerEventBuffer is how many events a subscriber may fall behind by
+const peerEventBuffer = 32
+
+// Membership tracks which peers are alive by their heartbeats. A peer joins
+// on its first heartbeat, or its first one after being lost, and is lost once
+// it has not been heard from within the timeout. Handlers are called outside
+// the membership's lock, so they may call back into it.
+type Membership struct {
+	timeout  time.Duration
+	mu       sync.Mutex
+	lastSeen map[int]time.Time // alive peers
+	lost     map[int]time.Time // when each lost peer was last heard from
+	handlers []func(PeerEvent)
+	start    sync.Once
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewMembership creates a membership with no peers
+func NewMembership(timeout time.Duration) *Membership {
+	return &Membership{
+		timeout:  timeout,
+		lastSeen: make(map[int]time.Time),
+		lost:     make(map[int]time.Time),
+		stop:     make(chan struct{}),
+	}
+}
+
+// OnEvent registers a handler for every event from now on
+func (m *Membership) OnEvent(handler func(PeerEvent)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.handlers = append(m.handlers, handler)
+}
+
+// Subscribe returns a channel receiving every event from now on. Events are
+// dropped if the reader falls behind, Alive is always up to date.
+func (m *Membership) Subscribe() <-chan PeerEvent {
+	events := make(chan PeerEvent, peerEventBuffer)
+	m.OnEvent(func(e PeerEvent) {
+		select {
+		case events <- e:
+		default:
+		}
+	})
+	return events
+}
+
+// Start runs the expiry timer until Stop is called. Calling it again does
+// nothing.
+func (m *Membership) Start() {
+	m.start.Do(func() { go m.run() })
+}
+
+// Stop ends the expiry timer. Peers are then only lost by calling Expire.
+// Calling it again does nothing.
+func (m *Membership) Stop() {
+	m.stopOnce.Do(func() { close(m.stop) })
+}
+
+func (m *Membership) run() {
+	timer := time.NewTimer(m.timeout)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-timer.C:
+			now := time.Now()
+			m.Expire(now)
+			timer.Reset(m.untilNextExpiry(now))
+		case <-m.stop:
+			return
+		}
+	}
+}
+
+// untilNextExpiry is how long until the peer heard from longest ago times
+// out. A peer joining later times out later.
+func (m *Membership) untilNextExpiry(now time.Time) time.Duration {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	next := m.timeout
+	for _, seen := range m.lastSeen {
+		next = min(next, seen.Add(m.timeout).Sub(now))
+	}
+	return max(next, time.Millisecond)
+}
+
+// Heartbeat records that the peer was heard from at the given time
+func (m *Membership) Heartbeat(id int, at time.Time) {
+	if e, joined := m.beat(id, at); joined {
+		m.dispatch(e)
+	}
+}
+
+// beat records a heartbeat like Heartbeat, but leaves reporting a join to the
+// caller. It returns the event to pass to dispatch, if the peer joined.
+func (m *Membership) beat(id int, at time.Time) (PeerEvent, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	last, alive := m.lastSeen[id]
+	if alive && at.Before(last) {
+		return PeerEvent{}, false
+	}
+
+	m.lastSeen[id] = at
+	if alive {
+		return PeerEvent{}, false
+	}
+
+	delete(m.lost, id)
+	return PeerEvent{ID: id, Kind: PeerJoined, At: at, LastSeen: at}, true
+}
+
+// dispatch calls every handler with the event
+func (m *Membership) dispatch(e PeerEvent) {
+	m.mu.Lock()
+	handlers := slices.Clone(m.handlers)
+	m.mu.Unlock()
+
+	for _, handle := range handlers {
+		handle(e)
+	}
+}
+
+// Expire marks every peer not heard from within the timeout as lost, and
+// returns their IDs
+func (m *Membership) Expire(now time.Time) []int {
+	m.mu.Lock()
+	var events []PeerEvent
+	for id, seen := range m.lastSeen {
+		if now.Sub(seen) <= m.timeout {
+			continue
+		}
+
+		delete(m.lastSeen, id)
+		m.lost[id] = seen
+		events = append(events, PeerEvent{ID: id, Kind: PeerLost, At: now, LastSeen: seen})
+	}
+	handlers := slices.Clone(m.handlers)
+	m.mu.Unlock()
+
+	slices.SortFunc(events, func(a, b PeerEvent) int {
+		return a.ID - b.ID
+	})
+
+	ids := make([]int, 0, len(events))
+	for _, e := range events {
+		for _, handle := range handlers {
+			handle(e)
+		}
+		ids = append(ids, e.ID)
+	}
+
+	return ids
+}
+
+// Isolated reports whether no peer is alive
+func (m *Membership) Isolated() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return len(m.lastSeen) == 0
+}
+
+// Alive returns the IDs of the alive peers, sorted
+func (m *Membership) Alive() []int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ids := make([]int, 0, len(m.lastSeen))
+	for id := range m.lastSeen {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+
+	return ids
+}
+
+// Lost returns the IDs of the peers lost and not heard from since, sorted
+func (m *Membership) Lost() []int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ids := make([]int, 0, len(m.lost))
+	for id := range m.lost {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+
+	return ids
+}
@@ -0,0 +1,178 @@
+package statesync
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMembership_JoinAndLose(t *testing.T) {
+	m := NewMembership(time.Second)
+	events := m.Subscribe()
+	start := time.Now()
+
+	m.Heartbeat(2, start)
+	m.Heartbeat(2, start.Add(500*time.Millisecond))
+	m.Heartbeat(3, start)
+
+	assert.Equal(t, PeerEvent{ID: 2, Kind: PeerJoined, At: start, LastSeen: start}, <-events)
+	assert.Equal(t, PeerEvent{ID: 3, Kind: PeerJoined, At: start, LastSeen: start}, <-events)
+	assert.Empty(t, events, "heartbeat from an alive peer is no event")
+
+	now := start.Add(1200 * time.Millisecond)
+	assert.Equal(t, []int{3}, m.Expire(now))
+	assert.Equal(t, PeerEvent{ID: 3, Kind: PeerLost, At: now, LastSeen: start}, <-events)
+	assert.Equal(t, []int{2}, m.Alive())
+	assert.Equal(t, []int{3}, m.Lost())
+
+	assert.Empty(t, m.Expire(now), "lost peer is only lost once")
+
+	m.Heartbeat(3, now)
+	assert.Equal(t, PeerJoined, (<-events).Kind, "lost peer should rejoin")
+	assert.Equal(t, []int{2, 3}, m.Alive())
+	assert.Empty(t, m.Lost())
+}
+
+func TestMembership_IgnoresOlderHeartbeat(t *testing.T) {
+	m := NewMembership(time.Second)
+	now := time.Now()
+
+	m.Heartbeat(2, now)
+	m.Heartbeat(2, now.Add(-time.Hour))
+
+	assert.Empty(t, m.Expire(now.Add(time.Second)))
+}
+
+// The timer expires peers with nothing else going on
+func TestMembership_ExpiresOnItsOwn(t *testing.T) {
+	m := NewMembership(50 * time.Millisecond)
+	events := m.Subscribe()
+	m.Start()
+
+	m.Heartbeat(2, time.Now())
+	require.Equal(t, PeerJoined, (<-events).Kind)
+
+	select {
+	case e := <-events:
+		assert.Equal(t, PeerEvent{ID: 2, Kind: PeerLost, At: e.At, LastSeen: e.LastSeen}, e)
+	case <-time.After(time.Second):
+		t.Fatal("peer was never lost")
+	}
+}
+
+// Merging a peer's worldview counts as a heartbeat, and losing it releases
+// its calls
+func TestWorldview_FollowsMembership(t *testing.T) {
+	wv1 := NewWorldView(1, 4)
+	wv2 := NewWorldView(2, 4)
+	events := wv1.Members().Subscribe()
+
+	require.NoError(t, wv2.SetHallCall(0, HDUp, HSAvailable))
+	require.NoError(t, wv2.SetHallCall(0, HDUp, HSProcessing))
+	require.NoError(t, wv1.Merge(wv2))
+
+	joined := <-events
+	assert.Equal(t, 2, joined.ID)
+	assert.Equal(t, PeerJoined, joined.Kind)
+	assert.Equal(t, []int{2}, wv1.Members().Alive())
+
+	assert.Equal(t, []int{2}, wv1.Members().Expire(time.Now().Add(2*NodeTimeoutDelay)))
+	assert.Equal(t, PeerLost, (<-events).Kind)
+	assert.Equal(t, []int{2}, wv1.GetLostElevatorIDs())
+	assert.Equal(t, HSAvailable, wv1.GetAllHallCalls()[0][HDUp].State, "lost elevator's call should be released")
+}
+
+// A loss of a peer merged from while the loss waited for the lock is ignored
+func TestWorldview_LossRacingMerge(t *testing.T) {
+	wv1 := NewWorldView(1, 4)
+	wv2 := NewWorldView(2, 4)
+	require.NoError(t, wv2.SetHallCall(0, HDUp, HSAvailable))
+	require.NoError(t, wv2.SetHallCall(0, HDUp, HSProcessing))
+	require.NoError(t, wv1.Merge(wv2))
+
+	// Expired just before the merge, handled just after it
+	wv1.onPeerEvent(PeerEvent{ID: 2, Kind: PeerLost, At: time.Now(), LastSeen: time.Now().Add(-2 * NodeTimeoutDelay)})
+
+	assert.Contains(t, wv1.elevatorStates, 2)
+	assert.Empty(t, wv1.GetLostElevatorIDs())
+	assert.Equal(t, HSProcessing, wv1.GetAllHallCalls()[0][HDUp].State, "call of the peer just heard from should be kept")
+}
+
+// Two nodes cut off from each other keep serving alone, and agree on every
+// call once they hear from each other again
+func TestWorldview_PartitionAndHeal(t *testing.T) {
+	wv1 := NewWorldView(1, 4)
+	wv2 := NewWorldView(2, 4)
+	require.NoError(t, wv1.Merge(wv2))
+	require.NoError(t, wv2.Merge(wv1))
+	require.NoError(t, wv2.SetHallCall(1, HDUp, HSAvailable))
+	require.NoError(t, wv2.SetHallCall(1, HDUp, HSProcessing))
+	require.NoError(t, wv1.Merge(wv2))
+	assert.False(t, wv1.Isolated())
+
+	later := time.Now().Add(2 * NodeTimeoutDelay)
+	wv1.Members().Expire(later)
+	wv2.Members().Expire(later)
+	require.True(t, wv1.Isolated())
+	require.True(t, wv2.Isolated())
+	assert.Equal(t, HSAvailable, wv1.GetConfirmedHallCalls()[1][HDUp].State, "call of the lost peer should be released")
+
+	// Each side takes in and serves calls on its own
+	require.NoError(t, wv1.SetHallCall(2, HDDown, HSAvailable))
+	assert.Equal(t, HSAvailable, wv1.GetConfirmedHallCalls()[2][HDDown].State, "alone, a call is confirmed at once")
+	require.NoError(t, wv1.SetHallCall(2, HDDown, HSProcessing))
+	require.NoError(t, wv2.SetHallCall(1, HDUp, HSNone))
+	require.NoError(t, wv2.SetHallCall(3, HDDown, HSAvailable))
+
+	for range 2 {
+		require.NoError(t, wv1.Merge(wv2))
+		require.NoError(t, wv2.Merge(wv1))
+	}
+
+	assert.False(t, wv1.Isolated())
+	assert.False(t, wv2.Isolated())
+	assert.Equal(t, wv1.GetAllHallCalls(), wv2.GetAllHallCalls(), "both sides should agree")
+
+	calls := wv1.GetConfirmedHallCalls()
+	assert.Equal(t, HallCallPairState{State: HSProcessing, By: 1}, HallCallPairState{State: calls[2][HDDown].State, By: calls[2][HDDown].By})
+	assert.Equal(t, HSAvailable, calls[3][HDDown].State, "call taken in during the partition should be kept")
+	assert.Equal(t, HSNone, wv1.GetAllHallCalls()[1][HDUp].State, "call served during the partition should stay served")
+}
+
+func TestMembership_Stop(t *testing.T) {
+	m := NewMembership(20 * time.Millisecond)
+	m.Start()
+	m.Stop()
+	m.Stop()
+
+	m.Heartbeat(2, time.Now())
+	time.Sleep(100 * time.Millisecond)
+
+	assert.Equal(t, []int{2}, m.Alive(), "a stopped membership should not expire peers by itself")
+}
+
+// A join handler may use the worldview whose merge reported the join
+func TestWorldview_JoinHandlerMayCallBack(t *testing.T) {
+	wv1 := NewWorldView(1, 4)
+	wv2 := NewWorldView(2, 4)
+
+	joined := make(chan []RemoteElevatorState, 1)
+	wv1.Members().OnEvent(func(e PeerEvent) {
+		if e.Kind == PeerJoined {
+			joined <- wv1.GetAllElevatorStates()
+		}
+	})
+
+	done := make(chan error)
+	go func() { done <- wv1.Merge(wv2) }()
+
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("merge deadlocked on the join handler")
+	}
+	assert.Len(t, <-joined, 2, "the handler should see the merged state")
+}